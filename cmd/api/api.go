@@ -16,11 +16,19 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 	"go.uber.org/zap"
 	"ontopsolutions.net/gasperlf/social/docs"
+	"ontopsolutions.net/gasperlf/social/internal/analytics"
 	"ontopsolutions.net/gasperlf/social/internal/auth"
+	"ontopsolutions.net/gasperlf/social/internal/blob"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
 	"ontopsolutions.net/gasperlf/social/internal/mailer"
+	"ontopsolutions.net/gasperlf/social/internal/preview"
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
 	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
+	"ontopsolutions.net/gasperlf/social/internal/search"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 	"ontopsolutions.net/gasperlf/social/internal/store/cache"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
 )
 
 type application struct {
@@ -31,6 +39,24 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	blobs         blob.Storage
+	previews      *preview.Fetcher
+	views         *analytics.Views
+	cursors       *cursor.Signer
+	scorer        ranking.Scorer
+
+	// exploreRateLimiter limits the public routes apart from the rest
+	exploreRateLimiter ratelimiter.Limiter
+	// mediaRateLimiter limits media downloads apart from the rest
+	mediaRateLimiter ratelimiter.Limiter
+	// searchIndex is nil when search runs on Postgres
+	searchIndex search.Index
+	// hub holds the live event subscribers of this instance
+	hub    *stream.Hub
+	events stream.Publisher
+	// wsRateLimiter limits the messages of each websocket connection
+	wsRateLimiter ratelimiter.Limiter
+	unsubscribes  *unsubscribe.Signer
 }
 
 type config struct {
@@ -43,6 +69,123 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	jobs        jobsConfig
+	posts       postsConfig
+	media       mediaConfig
+	previews    previewsConfig
+	analytics   analyticsConfig
+	pagination  paginationConfig
+	timeline    timelineConfig
+	ranking     rankingConfig
+	explore     exploreConfig
+	search      searchConfig
+	stream      streamConfig
+	websocket   websocketConfig
+}
+
+type postsConfig struct {
+	trashRetention time.Duration
+	maxLifetime    time.Duration
+}
+
+type mediaConfig struct {
+	dir           string
+	baseURL       string
+	maxUploadSize int64
+	orphanTTL     time.Duration
+	// rateLimiter limits downloads, a single page of posts loads many files
+	rateLimiter ratelimiter.Config
+}
+
+type previewsConfig struct {
+	timeout  time.Duration
+	maxBytes int64
+}
+
+type timelineConfig struct {
+	// capacity is how many posts a home timeline keeps
+	capacity int
+	// celebrityFollowers is the follower count above which the posts of a
+	// user are pulled into timelines on read instead of pushed on write
+	celebrityFollowers int
+}
+
+type streamConfig struct {
+	// heartbeat keeps idle connections open through proxies
+	heartbeat time.Duration
+	// retry is how long clients wait before reconnecting
+	retry time.Duration
+	// replaySize and replayTTL bound the events kept per topic for clients
+	// resuming after a disconnect
+	replaySize int
+	replayTTL  time.Duration
+	// bufferSize is how far a client can fall behind before it is dropped
+	bufferSize int
+	// writeWait is how long a write to a client may block before the
+	// client is taken as gone
+	writeWait time.Duration
+	// redis shares the events between instances, it needs redis enabled
+	redis bool
+}
+
+type websocketConfig struct {
+	maxMessageSize int64
+	// pingInterval is how often the server pings, a client not answering
+	// within pongWait is disconnected
+	pingInterval time.Duration
+	pongWait     time.Duration
+	writeWait    time.Duration
+	// sendBuffer is how many messages can wait for a client before it is
+	// dropped as too slow
+	sendBuffer       int
+	maxSubscriptions int
+	// rateLimiter limits the messages each connection sends
+	rateLimiter ratelimiter.Config
+}
+
+type searchConfig struct {
+	// backend is postgres or bleve
+	backend string
+	// indexDir is where the bleve index is stored
+	indexDir string
+}
+
+type exploreConfig struct {
+	// maxAge is how long clients and proxies may cache explore
+	maxAge      time.Duration
+	rateLimiter ratelimiter.Config
+}
+
+type rankingConfig struct {
+	// window is how far back the ranked feed looks for posts
+	window time.Duration
+	// candidates caps the posts ranked for a feed
+	candidates int
+	// maxConsecutive is how many posts of one author can follow each other
+	maxConsecutive int
+}
+
+type paginationConfig struct {
+	cursorSecret string
+}
+
+type analyticsConfig struct {
+	// viewWindow is how long a viewer seeing a post again doesn't count
+	viewWindow time.Duration
+}
+
+type jobsConfig struct {
+	enabled         bool
+	batchSize       int
+	publishInterval time.Duration
+	purgeInterval   time.Duration
+	mediaGCInterval time.Duration
+	previewInterval time.Duration
+	expireInterval  time.Duration
+	viewsInterval   time.Duration
+	digestInterval  time.Duration
+	// heldEmailsInterval is how late emails held by quiet hours can go out
+	heldEmailsInterval time.Duration
 }
 
 type redisConfig struct {
@@ -73,6 +216,10 @@ type mailConfig struct {
 	exp       time.Duration
 	sendGrid  sendGridConfig
 	mailTrap  mailTrapConfig
+	// unsubscribeSecret signs the unsubscribe links of emails
+	unsubscribeSecret string
+	// digestSize is how many notifications a digest lists
+	digestSize int
 }
 
 type sendGridConfig struct {
@@ -104,45 +251,98 @@ func mount(app *application) http.Handler {
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(requestLogger)
 	r.Use(middleware.Recoverer)
-	r.Use(app.RateLimiterMiddleware)
 
 	// Set a timeout value on the request context (ctx), that will signal
 	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
+	// processing should be stopped. The stream is long-lived and goes
+	// without it.
+	timeout := middleware.Timeout(60 * time.Second)
 	docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 
-	r.Route("/v1", func(r chi.Router) {
+	// explore is open to anonymous visitors, they get a limit of their own
+	r.With(app.ExploreRateLimiterMiddleware, timeout).Get("/v1/explore", app.getExploreHandler)
+	// a page of posts loads many images, they get a limit of their own
+	r.With(app.MediaRateLimiterMiddleware, timeout, app.OptionalAuthTokenMiddleware).Get("/v1/media/*", app.serveMediaHandler)
+
+	r.With(app.RateLimiterMiddleware, app.AuthTokenMiddleware).Get("/v1/stream", app.streamHandler)
+	r.With(app.RateLimiterMiddleware).Get("/v1/ws", app.websocketHandler)
+
+	r.With(app.RateLimiterMiddleware, timeout).Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
 		r.With(app.BasicMidleware()).
 			Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/", app.createPostHandler)
+			r.Get("/trash", app.getTrashHandler)
+			r.Post("/trash/{postID}/restore", app.restorePostHandler)
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postContextMiddleware)
 				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.DeletePostHandler)
+				r.Delete("/", app.CheckPostOwnership("admin", app.DeletePostHandler))
 				r.Patch("/", app.CheckPostOwnership("moderator", app.UpdatePostHandler))
-				r.Post("/comments", app.CheckPostOwnership("admin", app.createCommentPostHandler))
+				r.Put("/poll/votes", app.votePollHandler)
+				r.Put("/pin", app.pinPostHandler)
+				r.Delete("/pin", app.unpinPostHandler)
+				r.Put("/content-warning", app.forceContentWarningHandler)
+				r.Put("/expiry", app.extendPostHandler)
+				r.Get("/stats", app.getPostStatsHandler)
+				r.Route("/revisions", func(r chi.Router) {
+					// old versions may hold what the author edited out on purpose
+					r.Get("/", app.CheckPostOwnership("moderator", app.listPostRevisionsHandler))
+					r.Get("/diff", app.CheckPostOwnership("moderator", app.diffPostRevisionsHandler))
+					r.Post("/{version}/restore", app.CheckPostOwnership("moderator", app.restorePostRevisionHandler))
+				})
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.listCommentsHandler)
+					r.Post("/", app.createCommentPostHandler)
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentContextMiddleware)
+						r.Patch("/", app.updateCommentHandler)
+						r.Delete("/", app.CheckCommentOwnership("moderator", app.deleteCommentHandler))
+					})
+				})
 			})
 		})
+		r.With(app.AuthTokenMiddleware).Post("/media", app.uploadMediaHandler)
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Get("/", app.getUserHandler)
+				r.Get("/posts", app.getUserPostsHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/preferences", app.getPreferencesHandler)
+				r.Patch("/preferences", app.updatePreferencesHandler)
 			})
 
 		})
+		r.With(app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+		r.Route("/notifications", func(r chi.Router) {
+			// unsubscribe links in emails work without signing in
+			r.Post("/unsubscribe", app.unsubscribeHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/", app.listNotificationsHandler)
+				r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+				r.Put("/read", app.markAllNotificationsReadHandler)
+				r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+				r.Get("/settings", app.getNotificationSettingsHandler)
+				r.Patch("/settings", app.updateNotificationSettingsHandler)
+			})
+		})
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
@@ -166,6 +366,17 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs := app.startJobs(jobsCtx)
+
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	defer stopEvents()
+	go app.runEvents(eventsCtx)
+
+	// Shutdown doesn't wait for streams, ending them lets clients reconnect
+	// to another instance right away
+	srvr.RegisterOnShutdown(app.hub.Close)
+
 	shutdown := make(chan error)
 	go func() {
 		c := make(chan os.Signal, 1)
@@ -177,6 +388,9 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("caught shutdown signal", "signal", s.String())
 
+		stopJobs()
+		jobs.Wait()
+
 		shutdown <- srvr.Shutdown(ctx)
 	}()
 
@@ -190,6 +404,11 @@ func (app *application) run(mux http.Handler) error {
 
 	err = <-shutdown
 
+	// the server is drained, nothing records views anymore
+	if err := app.flushPostViews(context.Background()); err != nil {
+		app.logger.Errorw("failed to flush post views", "error", err.Error())
+	}
+
 	if err != nil {
 		return err
 	}
@@ -48,3 +48,50 @@ func TestRateLimiterMiddleware(t *testing.T) {
 		}
 	}
 }
+
+func TestMediaRateLimiterMiddleware(t *testing.T) {
+	cfg := config{
+		rateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 1,
+			TimeFrame:            time.Second * 5,
+			Enabled:              true,
+		},
+		media: mediaConfig{
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: 5,
+				TimeFrame:            time.Second * 5,
+				Enabled:              true,
+			},
+		},
+		addr: ":8080",
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := mount(app)
+
+	for i := 0; i < cfg.media.rateLimiter.RequestsPerTimeFrame+1; i++ {
+		req, err := http.NewRequest(http.MethodGet, "/v1/media/1/image.png", nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		req.Header.Set("X-Forwarded-For", "192.168.1.2")
+
+		rr := executeRequest(req, mux)
+		if i < cfg.media.rateLimiter.RequestsPerTimeFrame {
+			// the mock store has no attachments
+			checkResponseCode(t, http.StatusNotFound, rr.Code)
+		} else {
+			checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+		}
+	}
+
+	// uploads still go through the general limiter and need a token
+	req, err := http.NewRequest(http.MethodPost, "/v1/media", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+	req.Header.Set("X-Forwarded-For", "192.168.1.2")
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+}
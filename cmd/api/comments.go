@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+type commentKey string
+
+const contextKeyComment commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=500"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=500"`
+}
+
+// CreateComment godoc
+//
+//	@Summary		Create a comments for a post
+//	@Description	Create a comments for a post by ID, set parent_id to reply to another comment
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			request	body		CreateCommentPayload	true	"query params"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	var request CreateCommentPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+	comment := &store.Comment{
+		PostID:   post.ID,
+		ParentID: request.ParentID,
+		Content:  request.Content,
+		UserID:   user.ID,
+	}
+
+	ctx := r.Context()
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, errors.New("parent comment not found"))
+		case errors.Is(err, store.ErrMaxCommentDepth):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notifyCommentMentions(post, comment)
+	app.streamComment(post, comment, user.Username)
+	app.notifyComment(post, comment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ListComments godoc
+//
+//	@Summary		List the comments of a post
+//	@Description	List the top level comments of a post, or the replies of parent_id, oldest first
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			parent_id	query		int		false	"Parent comment ID"
+//	@Param			after		query		int		false	"Return comments after this comment ID"
+//	@Param			cursor		query		string	false	"Cursor from next_cursor or prev_cursor, instead of after"
+//	@Param			limit		query		int		false	"Limit"
+//	@Success		200			{object}	[]store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	cq := store.CommentPaginationQuery{
+		Limit: 20,
+	}
+
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if cq.Cursor != nil && cq.After > 0 {
+		app.badRequestResponse(w, r, errCursorWithOffset)
+		return
+	}
+
+	post := getPostFromContext(r)
+	comments, more, err := app.store.Comments.GetByPostID(r.Context(), post.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	offset := 0
+	if cq.After > 0 {
+		offset = 1
+	}
+	next, prev := app.pageCursors(cq.Cursor, offset, more, len(comments), func(i int) (time.Time, int64) {
+		return comments[i].CreatedAt, comments[i].ID
+	})
+
+	if err := app.jsonPageResponse(w, http.StatusOK, comments, next, prev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Edit a comment
+//	@Description	Edit a comment, only its author can do it
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			request		body		UpdateCommentPayload	true	"query params"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	var request UpdateCommentPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	comment := getCommentFromContext(r)
+	if comment.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("only the author can edit a comment"))
+		return
+	}
+
+	comment.Content = request.Content
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notifyCommentMentions(getPostFromContext(r), comment)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Soft delete a comment, allowed for its author and moderators
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{string}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	comment := getCommentFromContext(r)
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notContent(w)
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := getParamAsInt(r, "commentID")
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid comment id"))
+			return
+		}
+
+		ctx := r.Context()
+		comment, err := app.store.Comments.GetByID(ctx, commentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrorNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		post := getPostFromContext(r)
+		if comment.PostID != post.ID {
+			app.notFoundResponse(w, r, store.ErrorNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, contextKeyComment, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromContext(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(contextKeyComment).(*store.Comment)
+	return comment
+}
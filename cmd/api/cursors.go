@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+)
+
+var errCursorWithOffset = errors.New("cursor can't be combined with offset, after or before")
+
+// readCursor decodes the cursor query parameter, nil when there is none.
+func (app *application) readCursor(r *http.Request) (*cursor.Cursor, error) {
+	token := r.URL.Query().Get("cursor")
+	if token == "" {
+		return nil, nil
+	}
+
+	c, err := app.cursors.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// pageCursors returns the cursors to the pages around a page of n items read
+// from c, or from an offset when c is nil. position returns the creation time
+// and ID of the item at i. A page read backward always has one after it, and
+// one read forward has one before it unless it is the first page.
+func (app *application) pageCursors(c *cursor.Cursor, offset int, more bool, n int, position func(int) (time.Time, int64)) (next, prev string) {
+	backward := c != nil && c.Backward
+
+	if n == 0 {
+		// nothing there, point back to where the client came from
+		if c != nil {
+			turned := cursor.Cursor{CreatedAt: c.CreatedAt, ID: c.ID, Backward: !c.Backward}
+			if backward {
+				next = app.cursors.Encode(turned)
+			} else {
+				prev = app.cursors.Encode(turned)
+			}
+		}
+		return next, prev
+	}
+
+	if more || backward {
+		createdAt, id := position(n - 1)
+		next = app.cursors.Encode(cursor.Cursor{CreatedAt: createdAt, ID: id})
+	}
+
+	if (more && backward) || (!backward && (c != nil || offset > 0)) {
+		createdAt, id := position(0)
+		prev = app.cursors.Encode(cursor.Cursor{CreatedAt: createdAt, ID: id, Backward: true})
+	}
+
+	return next, prev
+}
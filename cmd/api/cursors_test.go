@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+)
+
+func TestPageCursors(t *testing.T) {
+	app := &application{cursors: cursor.NewSigner("secret")}
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// a page of three items, newest first
+	position := func(i int) (time.Time, int64) {
+		return base.Add(-time.Duration(i) * time.Minute), int64(10 - i)
+	}
+
+	decode := func(t *testing.T, token string) *cursor.Cursor {
+		t.Helper()
+
+		if token == "" {
+			return nil
+		}
+
+		c, err := app.cursors.Decode(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &c
+	}
+
+	t.Run("should only point forward from the first page", func(t *testing.T) {
+		next, prev := app.pageCursors(nil, 0, true, 3, position)
+
+		c := decode(t, next)
+		if c == nil || c.ID != 8 || c.Backward {
+			t.Errorf("got next %+v, want forward from the last item", c)
+		}
+		if prev != "" {
+			t.Errorf("got prev %+v, want none", decode(t, prev))
+		}
+	})
+
+	t.Run("should point back from an offset page", func(t *testing.T) {
+		_, prev := app.pageCursors(nil, 20, false, 3, position)
+
+		c := decode(t, prev)
+		if c == nil || c.ID != 10 || !c.Backward {
+			t.Errorf("got prev %+v, want backward from the first item", c)
+		}
+	})
+
+	t.Run("should point both ways from a middle page", func(t *testing.T) {
+		next, prev := app.pageCursors(&cursor.Cursor{CreatedAt: base, ID: 11}, 0, true, 3, position)
+
+		if c := decode(t, next); c == nil || c.ID != 8 || c.Backward {
+			t.Errorf("got next %+v, want forward from the last item", c)
+		}
+		if c := decode(t, prev); c == nil || c.ID != 10 || !c.Backward {
+			t.Errorf("got prev %+v, want backward from the first item", c)
+		}
+	})
+
+	t.Run("should not point back past the newest page read backward", func(t *testing.T) {
+		next, prev := app.pageCursors(&cursor.Cursor{CreatedAt: base, ID: 7, Backward: true}, 0, false, 3, position)
+
+		if c := decode(t, next); c == nil || c.ID != 8 || c.Backward {
+			t.Errorf("got next %+v, want forward from the last item", c)
+		}
+		if prev != "" {
+			t.Errorf("got prev %+v, want none", decode(t, prev))
+		}
+	})
+
+	t.Run("should turn back from an empty page", func(t *testing.T) {
+		from := cursor.Cursor{CreatedAt: base, ID: 5}
+		next, prev := app.pageCursors(&from, 0, false, 0, position)
+
+		if next != "" {
+			t.Errorf("got next %+v, want none", decode(t, next))
+		}
+		if c := decode(t, prev); c == nil || c.ID != 5 || !c.Backward {
+			t.Errorf("got prev %+v, want backward from the cursor", c)
+		}
+	})
+}
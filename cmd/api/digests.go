@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/mailer"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
+)
+
+type digestItem struct {
+	Summary string
+	URL     string
+}
+
+// sendDigests emails the digests that are due. Users in their quiet hours
+// wait for the next run, and each digest is claimed before it is sent so
+// instances running the job together send it once. A digest that fails is
+// released for the next run to retry.
+func (app *application) sendDigests(ctx context.Context) error {
+	now := time.Now()
+
+	var afterID int64
+	for {
+		recipients, err := app.store.NotificationSettings.DueDigests(ctx, now, afterID, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range recipients {
+			afterID = r.Settings.UserID
+
+			if r.Settings.Quiet(now) {
+				continue
+			}
+
+			claimed, err := app.store.NotificationSettings.ClaimDigest(ctx, r.Settings.UserID, r.Settings.LastDigestAt, now)
+			if err != nil {
+				return err
+			}
+
+			if !claimed {
+				continue
+			}
+
+			if err := app.sendDigest(ctx, r, now); err != nil {
+				app.logger.Errorw("error sending digest", "user_id", r.Settings.UserID, "error", err.Error())
+
+				if err := app.store.NotificationSettings.ReleaseDigest(ctx, r.Settings.UserID, r.Settings.LastDigestAt, now); err != nil {
+					app.logger.Errorw("failed to release digest", "user_id", r.Settings.UserID, "error", err.Error())
+				}
+			}
+		}
+
+		if len(recipients) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+// sendDigest sums up the unread notifications with activity since the last
+// digest, there is no email when there are none.
+func (app *application) sendDigest(ctx context.Context, r store.DigestRecipient, now time.Time) error {
+	since := now.Add(-r.Settings.DigestPeriod())
+	if r.Settings.LastDigestAt != nil {
+		since = *r.Settings.LastDigestAt
+	}
+
+	notifications, more, err := app.store.Notifications.List(ctx, r.Settings.UserID, store.NotificationsQuery{
+		Limit:  app.config.mail.digestSize,
+		Unread: true,
+		Since:  since,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	items := make([]digestItem, len(notifications))
+	for i, n := range notifications {
+		var actorID int64
+		if len(n.Actors) > 0 {
+			actorID = n.Actors[0].ID
+		}
+		items[i] = digestItem{
+			Summary: n.Summary,
+			URL:     app.notificationURL(n.Type, actorID, n.PostID, n.CommentID),
+		}
+	}
+
+	vars := struct {
+		Username          string
+		Period            string
+		Notifications     []digestItem
+		More              bool
+		InboxURL          string
+		UnsubscribeURL    string
+		UnsubscribeAllURL string
+	}{
+		Username:          r.Username,
+		Period:            r.Settings.Digest,
+		Notifications:     items,
+		More:              more,
+		InboxURL:          fmt.Sprintf("%s/notifications", app.config.frontendURL),
+		UnsubscribeURL:    app.unsubscribeURL(r.Settings.UserID, unsubscribeScopeDigest),
+		UnsubscribeAllURL: app.unsubscribeURL(r.Settings.UserID, unsubscribe.ScopeAll),
+	}
+
+	isProdEnv := app.config.env == "prod"
+	_, err = app.mailer.Send(mailer.DigestTemplate, r.Username, r.Email, vars, !isProdEnv)
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+type ExtendPostPayload struct {
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// ExtendPost godoc
+//
+//	@Summary		Extend an ephemeral post
+//	@Description	Push back the expiry of one of your ephemeral posts before it runs out
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			request	body		ExtendPostPayload	true	"New expiry"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/expiry [put]
+func (app *application) extendPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("you can only extend your own posts"))
+		return
+	}
+
+	var request ExtendPostPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if post.ExpiresAt == nil {
+		app.badRequestResponse(w, r, errors.New("the post doesn't expire"))
+		return
+	}
+
+	if !request.ExpiresAt.After(*post.ExpiresAt) {
+		app.badRequestResponse(w, r, errors.New("expires_at must be later than the current expiry"))
+		return
+	}
+
+	if err := app.checkPostExpiry(post, request.ExpiresAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Posts.Extend(r.Context(), post.ID, user.ID, request.ExpiresAt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			// it expired or was extended further in the meantime
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.ExpiresAt = &request.ExpiresAt
+	app.indexPosts(post.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkPostExpiry checks that expiresAt comes after the post is published,
+// and within the longest lifetime allowed from now on.
+func (app *application) checkPostExpiry(post *store.Post, expiresAt time.Time) error {
+	start := time.Now()
+	if post.Status == store.PostStatusScheduled && post.PublishAt != nil {
+		start = *post.PublishAt
+	}
+
+	if !expiresAt.After(start) {
+		return errors.New("expires_at must be after the post is published")
+	}
+
+	maxLifetime := app.config.posts.maxLifetime
+	if expiresAt.After(start.Add(maxLifetime)) {
+		return fmt.Errorf("posts can't last longer than %d days", int(maxLifetime.Hours()/24))
+	}
+
+	return nil
+}
+
+// purgeExpiredPosts removes the ephemeral posts that ran out, there is no
+// trash for them.
+func (app *application) purgeExpiredPosts(ctx context.Context) error {
+	for {
+		ids, attachments, err := app.store.Posts.PurgeExpired(ctx, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range attachments {
+			app.deleteBlobs(ctx, a.StorageKey, a.ThumbnailKey)
+		}
+
+		if len(ids) > 0 {
+			app.logger.Infow("expired posts purged", "count", len(ids), "attachments", len(attachments))
+		}
+
+		app.indexPosts(ids...)
+
+		if len(ids) == 0 || len(ids) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+const (
+	explorePosts = 20
+	exploreUsers = 10
+)
+
+// GetExplore godoc
+//
+//	@Summary		Explore public content
+//	@Description	Recent public posts, the popular ones within a time window and users to follow, no authentication needed
+//	@Tags			explore
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"Time window of popular posts and suggested users: 1h, 24h or 7d"
+//	@Success		200		{object}	store.Explore
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/explore [get]
+func (app *application) getExploreHandler(w http.ResponseWriter, r *http.Request) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "24h"
+	}
+
+	window, ok := trendingWindows[windowParam]
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("window must be one of 1h, 24h or 7d"))
+		return
+	}
+
+	explore, err := app.getExplore(r, window)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the same for everybody, let browsers and proxies keep it too
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(app.config.explore.maxAge.Seconds())))
+
+	if err := app.jsonResponse(w, http.StatusOK, explore); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getExplore serves explore from the cache, which is redis or, without it,
+// in process. Cache failures fall back to the database.
+func (app *application) getExplore(r *http.Request, window time.Duration) (*store.Explore, error) {
+	ctx := r.Context()
+
+	explore, err := app.cacheStore.Explore.Get(ctx, window)
+	if err != nil {
+		app.logger.Errorw("failed to read cached explore", "window", window.String(), "error", err.Error())
+	}
+
+	if explore != nil {
+		return explore, nil
+	}
+
+	explore, err = app.loadExplore(r, window)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStore.Explore.Set(ctx, window, explore); err != nil {
+		app.logger.Errorw("failed to cache explore", "window", window.String(), "error", err.Error())
+	}
+
+	return explore, nil
+}
+
+func (app *application) loadExplore(r *http.Request, window time.Duration) (*store.Explore, error) {
+	ctx := r.Context()
+
+	recent, err := app.store.Posts.GetRecentPublic(ctx, explorePosts)
+	if err != nil {
+		return nil, err
+	}
+
+	popular, err := app.store.Posts.GetPopular(ctx, window, explorePosts)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := app.store.Users.GetSuggested(ctx, window, exploreUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.Explore{Recent: recent, Popular: popular, SuggestedUsers: users}, nil
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"ontopsolutions.net/gasperlf/social/internal/store"
 )
@@ -17,8 +19,11 @@ import (
 //	@Param			until	query		string	false	"Until"
 //	@Param			limit	query		int		false	"Limit"
 //	@Param			offset	query		int		false	"Offset"
+//	@Param			cursor	query		string	false	"Cursor from next_cursor or prev_cursor, instead of offset"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			param	query		string	false	"Param"
+//	@Param			mode	query		string	false	"chronological (default) or ranked"
+//	@Param			debug	query		bool	false	"Include the ranking of each post, ranked mode outside production only"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
@@ -45,16 +50,59 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	fq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if fq.Cursor != nil && fq.Offset > 0 {
+		app.badRequestResponse(w, r, errCursorWithOffset)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	switch r.URL.Query().Get("mode") {
+	case "", feedModeChronological:
+	case feedModeRanked:
+		app.rankedFeed(w, r, user, fq)
+		return
+	default:
+		app.badRequestResponse(w, r, errInvalidFeedMode)
+		return
+	}
+
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(27), fq)
+	feed, more, err := app.getUserFeed(ctx, user.ID, fq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	app.recordFeedViews(r, feed)
+
+	next, prev := app.pageCursors(fq.Cursor, fq.Offset, more, len(feed), func(i int) (time.Time, int64) {
+		return feed[i].CreatedAt, feed[i].ID
+	})
+
+	if err := app.jsonPageResponse(w, http.StatusOK, feed, next, prev); err != nil {
 		app.internalServerError(w, r, err)
 	}
 
 }
+
+// getUserFeed reads the home feed from the timeline when it can, and from the
+// database otherwise or when Redis fails.
+func (app *application) getUserFeed(ctx context.Context, userID int64, fq store.PaginationFeedQuery) ([]store.PostWithMetadata, bool, error) {
+	if app.usesTimeline(fq) {
+		feed, more, err := app.timelineFeed(ctx, userID, fq)
+		if err == nil {
+			return feed, more, nil
+		}
+		app.logger.Errorw("failed to read timeline, falling back to the database", "user_id", userID, "error", err.Error())
+	}
+
+	return app.store.Posts.GetUserFeed(ctx, userID, fq)
+}
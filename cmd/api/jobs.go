@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// job is a periodic background task. Every API instance runs the same jobs,
+// so each one has to be safe to run concurrently across instances. Local jobs
+// work on state of the instance itself and run even when jobs are disabled.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(context.Context) error
+	local    bool
+}
+
+func (app *application) backgroundJobs() []job {
+	return []job{
+		{
+			name:     "publish scheduled posts",
+			interval: app.config.jobs.publishInterval,
+			run:      app.publishScheduledPosts,
+		},
+		{
+			name:     "purge deleted posts",
+			interval: app.config.jobs.purgeInterval,
+			run:      app.purgeDeletedPosts,
+		},
+		{
+			name:     "purge expired posts",
+			interval: app.config.jobs.expireInterval,
+			run:      app.purgeExpiredPosts,
+		},
+		{
+			name:     "collect orphaned media",
+			interval: app.config.jobs.mediaGCInterval,
+			run:      app.collectOrphanedMedia,
+		},
+		{
+			name:     "fetch link previews",
+			interval: app.config.jobs.previewInterval,
+			run:      app.fetchLinkPreviews,
+		},
+		{
+			name:     "flush post views",
+			interval: app.config.jobs.viewsInterval,
+			run:      app.flushPostViews,
+			local:    true,
+		},
+		{
+			name:     "send digests",
+			interval: app.config.jobs.digestInterval,
+			run:      app.sendDigests,
+		},
+		{
+			name:     "send held notification emails",
+			interval: app.config.jobs.heldEmailsInterval,
+			run:      app.sendHeldEmails,
+		},
+	}
+}
+
+// startJobs runs every background job on its own goroutine until ctx is
+// cancelled, only the local ones when jobs are disabled. The returned
+// WaitGroup is done once all of them have stopped.
+func (app *application) startJobs(ctx context.Context) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+
+	for _, j := range app.backgroundJobs() {
+		if !app.config.jobs.enabled && !j.local {
+			continue
+		}
+
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := j.run(ctx); err != nil {
+						app.logger.Errorw("background job failed", "job", j.name, "error", err.Error())
+					}
+				}
+			}
+		}(j)
+	}
+
+	return wg
+}
+
+// background runs fn on its own goroutine, for work that should not hold up
+// the response. Panics are logged instead of taking the server down.
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", fmt.Sprint(err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	for {
+		ids, err := app.store.Posts.PublishScheduled(ctx, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			app.logger.Infow("scheduled posts published", "count", len(ids))
+		}
+
+		for _, id := range ids {
+			app.announcePublishedPost(ctx, id)
+		}
+
+		if len(ids) == 0 || len(ids) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+// announcePublishedPost runs the side effects of publishing a scheduled post
+// that a directly published post gets from its create handler.
+func (app *application) announcePublishedPost(ctx context.Context, postID int64) {
+	post, err := app.store.Posts.GetByID(ctx, postID)
+	if err != nil {
+		app.logger.Errorw("failed to load published post", "post_id", postID, "error", err.Error())
+		return
+	}
+
+	app.notifyPostMentions(post)
+	app.fanOutPost(post)
+	app.streamPost(post)
+	app.indexPosts(post.ID)
+}
@@ -10,8 +10,11 @@ import (
 var Validate *validator.Validate
 
 type Envelope struct {
-	Data  any    `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
+	Data       any    `json:"data,omitempty"`
+	Error      string `json:"error,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Facets     any    `json:"facets,omitempty"`
 }
 
 func init() {
@@ -53,3 +56,14 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &Envelope{Data: data})
 }
+
+// jsonPageResponse writes a page of a list along with the cursors to the
+// pages next to it.
+func (app *application) jsonPageResponse(w http.ResponseWriter, status int, data any, next, prev string) error {
+	return writeJSON(w, status, &Envelope{Data: data, NextCursor: next, PrevCursor: prev})
+}
+
+// jsonFacetsResponse writes search results along with their facets.
+func (app *application) jsonFacetsResponse(w http.ResponseWriter, status int, data, facets any) error {
+	return writeJSON(w, status, &Envelope{Data: data, Facets: facets})
+}
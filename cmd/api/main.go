@@ -1,17 +1,28 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	"ontopsolutions.net/gasperlf/social/internal/analytics"
 	"ontopsolutions.net/gasperlf/social/internal/auth"
+	"ontopsolutions.net/gasperlf/social/internal/blob"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
 	"ontopsolutions.net/gasperlf/social/internal/db"
 	"ontopsolutions.net/gasperlf/social/internal/env"
 	"ontopsolutions.net/gasperlf/social/internal/mailer"
+	"ontopsolutions.net/gasperlf/social/internal/preview"
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
 	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
+	"ontopsolutions.net/gasperlf/social/internal/search"
+	"ontopsolutions.net/gasperlf/social/internal/search/bleveindex"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 	"ontopsolutions.net/gasperlf/social/internal/store/cache"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
 )
 
 const version = "1.1.0"
@@ -62,6 +73,8 @@ func main() {
 			mailTrap: mailTrapConfig{
 				apiKey: env.GetString("MAIL_API_KEY", ""),
 			},
+			unsubscribeSecret: env.GetString("UNSUBSCRIBE_SECRET", ""),
+			digestSize:        20,
 		},
 		auth: authConfig{
 			basic: basicConfig{
@@ -79,6 +92,86 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
 		},
+		jobs: jobsConfig{
+			enabled:            env.GetBool("JOBS_ENABLED", true),
+			batchSize:          env.GetInt("JOBS_BATCH_SIZE", 100),
+			publishInterval:    time.Second * 30,
+			purgeInterval:      time.Hour,
+			mediaGCInterval:    time.Hour,
+			previewInterval:    time.Second * 10,
+			expireInterval:     time.Minute,
+			viewsInterval:      time.Second * 30,
+			digestInterval:     time.Hour,
+			heldEmailsInterval: time.Minute,
+		},
+		posts: postsConfig{
+			trashRetention: time.Hour * 24 * time.Duration(env.GetInt("POST_TRASH_RETENTION_DAYS", 30)),
+			maxLifetime:    time.Hour * 24 * time.Duration(env.GetInt("POST_MAX_LIFETIME_DAYS", 7)),
+		},
+		media: mediaConfig{
+			dir:           env.GetString("MEDIA_DIR", "./media"),
+			baseURL:       env.GetString("MEDIA_BASE_URL", "http://localhost:8081/v1/media"),
+			maxUploadSize: int64(env.GetInt("MEDIA_MAX_UPLOAD_MB", 8)) << 20,
+			orphanTTL:     time.Hour * 24,
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("MEDIA_RATE_LIMIT_REQUESTS_PER_TIME_FRAME", 300),
+				TimeFrame:            time.Minute,
+				Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
+			},
+		},
+		previews: previewsConfig{
+			timeout:  time.Second * 5,
+			maxBytes: 512 << 10,
+		},
+		analytics: analyticsConfig{
+			viewWindow: time.Minute * 30,
+		},
+		timeline: timelineConfig{
+			capacity:           env.GetInt("TIMELINE_CAPACITY", 800),
+			celebrityFollowers: env.GetInt("TIMELINE_CELEBRITY_FOLLOWERS", 10000),
+		},
+		ranking: rankingConfig{
+			window:         time.Hour * 24 * time.Duration(env.GetInt("RANKING_WINDOW_DAYS", 3)),
+			candidates:     env.GetInt("RANKING_CANDIDATES", 500),
+			maxConsecutive: 2,
+		},
+		explore: exploreConfig{
+			maxAge: time.Minute,
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("EXPLORE_RATE_LIMIT_REQUESTS_PER_TIME_FRAME", 30),
+				TimeFrame:            time.Minute,
+				Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
+			},
+		},
+		search: searchConfig{
+			backend:  env.GetString("SEARCH_BACKEND", searchBackendPostgres),
+			indexDir: env.GetString("SEARCH_INDEX_DIR", "./search-index"),
+		},
+		stream: streamConfig{
+			heartbeat:  time.Second * 15,
+			retry:      time.Second * 3,
+			replaySize: env.GetInt("STREAM_REPLAY_SIZE", 100),
+			replayTTL:  time.Minute * 5,
+			bufferSize: 32,
+			writeWait:  time.Second * 10,
+			redis:      env.GetBool("STREAM_REDIS_ENABLED", true),
+		},
+		websocket: websocketConfig{
+			maxMessageSize:   4096,
+			pingInterval:     time.Second * 30,
+			pongWait:         time.Minute,
+			writeWait:        time.Second * 10,
+			sendBuffer:       64,
+			maxSubscriptions: env.GetInt("WS_MAX_SUBSCRIPTIONS", 50),
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("WS_RATE_LIMIT_MESSAGES_PER_TIME_FRAME", 20),
+				TimeFrame:            time.Second * 10,
+				Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
+			},
+		},
+		pagination: paginationConfig{
+			cursorSecret: env.GetString("CURSOR_SECRET", ""),
+		},
 	}
 
 	logger := zap.Must(zap.NewProduction()).Sugar()
@@ -88,6 +181,23 @@ func main() {
 		}
 	}()
 
+	// cursors and unsubscribe links signed with a known secret can be forged
+	for name, secret := range map[string]*string{
+		"CURSOR_SECRET":      &cfg.pagination.cursorSecret,
+		"UNSUBSCRIBE_SECRET": &cfg.mail.unsubscribeSecret,
+	} {
+		if *secret != "" {
+			continue
+		}
+
+		if cfg.env == "prod" {
+			logger.Fatalw("secret is not set", "env", name)
+		}
+
+		*secret = randomSecret()
+		logger.Warnw("secret is not set, using a random one until restart", "env", name)
+	}
+
 	db, err := db.New(
 		cfg.db.addr,
 		cfg.db.maxOpenConns,
@@ -119,6 +229,21 @@ func main() {
 	logger.Info("redis client initialized")
 
 	// rate limiter initialization would go here if needed
+	exploreRateLimiter := ratelimiter.NewFixedWindowRateLimiter(
+		cfg.explore.rateLimiter.RequestsPerTimeFrame,
+		cfg.explore.rateLimiter.TimeFrame,
+	)
+
+	mediaRateLimiter := ratelimiter.NewFixedWindowRateLimiter(
+		cfg.media.rateLimiter.RequestsPerTimeFrame,
+		cfg.media.rateLimiter.TimeFrame,
+	)
+
+	wsRateLimiter := ratelimiter.NewFixedWindowRateLimiter(
+		cfg.websocket.rateLimiter.RequestsPerTimeFrame,
+		cfg.websocket.rateLimiter.TimeFrame,
+	)
+
 	ratelimiter := ratelimiter.NewFixedWindowRateLimiter(
 		cfg.rateLimiter.RequestsPerTimeFrame,
 		cfg.rateLimiter.TimeFrame,
@@ -126,9 +251,48 @@ func main() {
 
 	store := store.NewStorage(db)
 	cacheStore := cache.NewRedisStorage(rdb)
+	if !cfg.redisCfg.enabled {
+		// explore is hit by every anonymous visitor, it stays cached anyway
+		cacheStore.Explore = &cache.MemoryExploreStore{}
+	}
+
+	blobs, err := blob.NewLocalStorage(cfg.media.dir, cfg.media.baseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
+	var searchIndex search.Index
+	switch cfg.search.backend {
+	case searchBackendPostgres:
+	case searchBackendBleve:
+		idx, err := bleveindex.Open(cfg.search.indexDir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer func() {
+			if err := idx.Close(); err != nil {
+				logger.Error("failed to close search index", "error", err)
+			}
+		}()
+		searchIndex = idx
+		logger.Infow("search index opened", "dir", cfg.search.indexDir)
+	default:
+		logger.Fatalw("unknown search backend", "backend", cfg.search.backend)
+	}
+
+	hub := stream.NewHub(stream.Config{
+		ReplaySize: cfg.stream.replaySize,
+		ReplayTTL:  cfg.stream.replayTTL,
+		BufferSize: cfg.stream.bufferSize,
+	})
+
+	var events stream.Publisher = stream.NewLocal(hub)
+	if cfg.redisCfg.enabled && cfg.stream.redis {
+		events = stream.NewRedis(rdb, hub)
+	}
+
 	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
 
 	app := &application{
@@ -139,9 +303,32 @@ func main() {
 		authenticator: jwtAuthenticator,
 		cacheStore:    cacheStore,
 		rateLimiter:   ratelimiter,
+		blobs:         blobs,
+		previews: preview.NewFetcher(preview.Config{
+			Timeout:  cfg.previews.timeout,
+			MaxBytes: cfg.previews.maxBytes,
+		}),
+		views:   analytics.NewViews(cfg.analytics.viewWindow),
+		cursors: cursor.NewSigner(cfg.pagination.cursorSecret),
+		scorer:  ranking.DefaultScorer(),
+
+		exploreRateLimiter: exploreRateLimiter,
+		mediaRateLimiter:   mediaRateLimiter,
+		searchIndex:        searchIndex,
+		hub:                hub,
+		events:             events,
+		wsRateLimiter:      wsRateLimiter,
+		unsubscribes:       unsubscribe.NewSigner(cfg.mail.unsubscribeSecret),
 	}
 
 	mux := mount(app)
 	logger.Fatal(app.run(mux))
 
 }
+
+// randomSecret stands in for secrets left unset outside of prod.
+func randomSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"ontopsolutions.net/gasperlf/social/internal/blob"
+	"ontopsolutions.net/gasperlf/social/internal/media"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+type AttachmentPayload struct {
+	ID      int64  `json:"id" validate:"required,gte=1"`
+	AltText string `json:"alt_text" validate:"max=1500"`
+}
+
+// UploadMedia godoc
+//
+//	@Summary		Upload an image
+//	@Description	Upload a JPEG, PNG, GIF or WebP image to attach to a post. Uploads no post references are deleted after a while.
+//	@Tags			media
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//	@Success		201		{object}	store.Attachment
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	maxSize := app.config.media.maxUploadSize
+
+	// leave room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("the image must be sent in the file field of a multipart form"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if int64(len(data)) > maxSize {
+		app.badRequestResponse(w, r, fmt.Errorf("images can't be larger than %d MB", maxSize>>20))
+		return
+	}
+
+	img, err := media.Process(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedFormat), errors.Is(err, media.ErrImageTooLarge):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	name := uuid.New().String()
+	attachment := &store.Attachment{
+		UserID:       user.ID,
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		Blurhash:     img.Blurhash,
+		StorageKey:   "attachments/" + name + img.Extension,
+		ThumbnailKey: "attachments/" + name + "_thumb.jpg",
+	}
+	attachment.URL = app.blobs.URL(attachment.StorageKey)
+	attachment.ThumbnailURL = app.blobs.URL(attachment.ThumbnailKey)
+
+	if err := app.blobs.Put(ctx, attachment.StorageKey, bytes.NewReader(data), img.ContentType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.blobs.Put(ctx, attachment.ThumbnailKey, bytes.NewReader(img.Thumbnail), "image/jpeg"); err != nil {
+		app.deleteBlobs(ctx, attachment.StorageKey)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Attachments.Create(ctx, attachment); err != nil {
+		app.deleteBlobs(ctx, attachment.StorageKey, attachment.ThumbnailKey)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// serveMediaHandler serves the blobs of the local storage backend to the
+// viewers of the post they are attached to, anonymous viewers only get the
+// images of public posts. Keys are random and never reused, so clients can
+// cache them for good, but not shared caches as visibility can change.
+func (app *application) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	ctx := r.Context()
+
+	visible, err := app.store.Attachments.CanView(ctx, viewerID(r), key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.notFoundResponse(w, r, blob.ErrNotFound)
+		return
+	}
+
+	f, err := app.blobs.Open(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound), errors.Is(err, blob.ErrInvalidKey):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+
+	if _, err := io.Copy(w, f); err != nil {
+		app.logger.Errorw("failed to serve media", "key", key, "error", err.Error())
+	}
+}
+
+// collectOrphanedMedia deletes the uploads no post referenced within the
+// orphan TTL, along with the uploads of purged posts.
+func (app *application) collectOrphanedMedia(ctx context.Context) error {
+	before := time.Now().Add(-app.config.media.orphanTTL)
+
+	for {
+		attachments, err := app.store.Attachments.DeleteOrphaned(ctx, before, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range attachments {
+			app.deleteBlobs(ctx, a.StorageKey, a.ThumbnailKey)
+		}
+
+		if len(attachments) > 0 {
+			app.logger.Infow("orphaned media deleted", "count", len(attachments))
+		}
+
+		if len(attachments) == 0 || len(attachments) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+// deleteBlobs removes blobs on a best effort basis, a leftover blob only
+// wastes space.
+func (app *application) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Errorw("failed to delete blob", "key", key, "error", err.Error())
+		}
+	}
+}
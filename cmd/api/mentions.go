@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// notifyPostMentions tells the users mentioned in a published post about it.
+// Mentions are claimed in the store, so each user is notified once per post
+// no matter how many times it is edited.
+func (app *application) notifyPostMentions(post *store.Post) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+
+	app.background(func() {
+		ctx := context.Background()
+		users, err := app.store.Mentions.ClaimPostMentions(ctx, post.ID)
+		if err != nil {
+			app.logger.Errorw("failed to claim post mentions", "post_id", post.ID, "error", err.Error())
+			return
+		}
+
+		app.sendMentions(users, store.NotificationEvent{
+			ActorID: post.UserID,
+			Type:    store.NotificationMention,
+			PostID:  &post.ID,
+		})
+	})
+}
+
+// notifyCommentMentions tells the users mentioned in a comment about it.
+func (app *application) notifyCommentMentions(post *store.Post, comment *store.Comment) {
+	app.background(func() {
+		ctx := context.Background()
+		users, err := app.store.Mentions.ClaimCommentMentions(ctx, comment.ID)
+		if err != nil {
+			app.logger.Errorw("failed to claim comment mentions", "comment_id", comment.ID, "error", err.Error())
+			return
+		}
+
+		app.sendMentions(users, store.NotificationEvent{
+			ActorID:   comment.UserID,
+			Type:      store.NotificationMention,
+			PostID:    &post.ID,
+			CommentID: &comment.ID,
+		})
+	})
+}
+
+// sendMentions notifies the mentioned users, the store only claims the
+// mentions of users allowed to see the post so mentioning someone doesn't
+// give them access to it. mention is the notification each of them gets.
+func (app *application) sendMentions(users []store.User, mention store.NotificationEvent) {
+	for _, user := range users {
+		mention.UserID = user.ID
+		app.notify(mention)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 )
 
@@ -66,22 +70,8 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizeErrorResponse(w, r, err)
-			return
-		}
-		//add the claims to the context
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64) //claims["sub"].(string)
-		if err != nil {
-			app.unauthorizeErrorResponse(w, r, err)
-			return
-		}
-
 		ctx := r.Context()
-		user, err := app.getUser(ctx, userID)
+		user, err := app.authenticateToken(ctx, parts[1])
 		if err != nil {
 			app.unauthorizeErrorResponse(w, r, err)
 			return
@@ -93,6 +83,37 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// OptionalAuthTokenMiddleware authenticates the requests that carry a token
+// and lets anonymous ones through, for routes that serve public content too.
+func (app *application) OptionalAuthTokenMiddleware(next http.Handler) http.Handler {
+	authenticated := app.AuthTokenMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken validates a bearer token and loads its user.
+func (app *application) authenticateToken(ctx context.Context, token string) (*store.User, error) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64) //claims["sub"].(string)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.getUser(ctx, userID)
+}
+
 func (app *application) CheckPostOwnership(roleRequired string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -121,6 +142,34 @@ func (app *application) CheckPostOwnership(roleRequired string, next http.Handle
 	})
 }
 
+func (app *application) CheckCommentOwnership(roleRequired string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// if it is the author of the comment
+		user := getUserFromContext(r)
+		comment := getCommentFromContext(r)
+
+		if user.ID == comment.UserID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		//role precedence check
+		allowance, err := app.checkRolePrecedence(r.Context(), user, roleRequired)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowance {
+			app.forbiddenErrorResponse(w, r, fmt.Errorf("you don't have the required permissions to perform this action"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 
 	// find the role of the user
@@ -159,9 +208,21 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 }
 
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
+	return app.rateLimit(app.rateLimiter, app.config.rateLimiter, next)
+}
+
+func (app *application) ExploreRateLimiterMiddleware(next http.Handler) http.Handler {
+	return app.rateLimit(app.exploreRateLimiter, app.config.explore.rateLimiter, next)
+}
+
+func (app *application) MediaRateLimiterMiddleware(next http.Handler) http.Handler {
+	return app.rateLimit(app.mediaRateLimiter, app.config.media.rateLimiter, next)
+}
+
+func (app *application) rateLimit(limiter ratelimiter.Limiter, cfg ratelimiter.Config, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
+		if cfg.Enabled {
+			if allow, retryAfter := limiter.Allow(r.RemoteAddr); !allow {
 				app.rateLimitExceededResponse(w, r, retryAfter.String())
 				return
 			}
@@ -169,3 +230,30 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestLogger logs requests like middleware.Logger, with the access_token param
+// redacted: WebSocket clients that can't set headers pass their bearer token
+// in it.
+var requestLogger = middleware.RequestLogger(redactingLogFormatter{
+	&middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags), NoColor: true},
+})
+
+type redactingLogFormatter struct {
+	middleware.LogFormatter
+}
+
+func (f redactingLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	qs := r.URL.Query()
+	if !qs.Has("access_token") {
+		return f.LogFormatter.NewLogEntry(r)
+	}
+	qs.Set("access_token", "REDACTED")
+
+	u := *r.URL
+	u.RawQuery = qs.Encode()
+
+	logged := *r
+	logged.URL = &u
+	logged.RequestURI = u.RequestURI()
+	return f.LogFormatter.NewLogEntry(&logged)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := middleware.RequestLogger(redactingLogFormatter{
+		&middleware.DefaultLogFormatter{Logger: log.New(&buf, "", 0), NoColor: true},
+	})
+
+	var token string
+	handler := logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.URL.Query().Get("access_token")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/ws?access_token=secret&v=1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	t.Run("should keep the token out of the log", func(t *testing.T) {
+		if strings.Contains(buf.String(), "secret") {
+			t.Errorf("got %q, want the token redacted", buf.String())
+		}
+		if !strings.Contains(buf.String(), "/v1/ws?access_token=REDACTED&v=1") {
+			t.Errorf("got %q, want the rest of the request", buf.String())
+		}
+	})
+
+	t.Run("should pass the token to the handler", func(t *testing.T) {
+		if token != "secret" {
+			t.Errorf("got token %q, want %q", token, "secret")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+type ForceContentWarningPayload struct {
+	ContentWarning string `json:"content_warning" validate:"required,max=200"`
+	Reason         string `json:"reason" validate:"required,max=500"`
+}
+
+// ForceContentWarning godoc
+//
+//	@Summary		Force a content warning
+//	@Description	Put a content warning on a post that its author can't remove, allowed for moderators. The action is recorded in the moderation log
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int							true	"Post ID"
+//	@Param			request	body		ForceContentWarningPayload	true	"Content warning"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/content-warning [put]
+func (app *application) forceContentWarningHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	allowed, err := app.checkRolePrecedence(ctx, user, "moderator")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenErrorResponse(w, r, errors.New("only moderators can force a content warning"))
+		return
+	}
+
+	var request ForceContentWarningPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromContext(r)
+
+	if err := app.store.Moderation.ForceContentWarning(ctx, post.ID, user.ID, request.ContentWarning, request.Reason); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("content warning forced", "post_id", post.ID, "moderator_id", user.ID, "reason", request.Reason)
+	app.indexPosts(post.ID)
+
+	app.notContent(w)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
+)
+
+// unsubscribeScopeDigest stops the digest, the other scopes are notification
+// types or all of them.
+const unsubscribeScopeDigest = "digest"
+
+var errInvalidQuietHours = errors.New("quiet_start and quiet_end go together")
+
+type UpdateNotificationSettingsPayload struct {
+	Deliveries map[string]string `json:"deliveries" validate:"dive,oneof=in_app email all none"`
+	QuietStart *string           `json:"quiet_start" validate:"omitempty,datetime=15:04"`
+	QuietEnd   *string           `json:"quiet_end" validate:"omitempty,datetime=15:04"`
+	Timezone   *string           `json:"timezone" validate:"omitempty,timezone"`
+	Digest     *string           `json:"digest" validate:"omitempty,oneof=off daily weekly"`
+}
+
+type UnsubscribePayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// GetNotificationSettings godoc
+//
+//	@Summary		Get your notification settings
+//	@Description	Get how each type of notification reaches you, your quiet hours and your digest
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	store.NotificationSettings
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/settings [get]
+func (app *application) getNotificationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	settings, err := app.store.NotificationSettings.Get(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, settings); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateNotificationSettings godoc
+//
+//	@Summary		Update your notification settings
+//	@Description	Choose per notification type whether it reaches you in the app (in_app), by email (email), both (all) or not at all (none). Quiet hours, in your timezone, hold back emails and live updates, empty to turn them off. The digest emails a summary of your unread notifications daily or weekly.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		UpdateNotificationSettingsPayload	true	"Settings"
+//	@Success		200		{object}	store.NotificationSettings
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/settings [patch]
+func (app *application) updateNotificationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateNotificationSettingsPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for typ := range request.Deliveries {
+		if !store.IsNotificationType(typ) {
+			app.badRequestResponse(w, r, fmt.Errorf("unknown notification type %q", typ))
+			return
+		}
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	settings, err := app.store.NotificationSettings.Get(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for typ, delivery := range request.Deliveries {
+		settings.Deliveries[typ] = delivery
+	}
+	if request.QuietStart != nil {
+		settings.QuietStart = *request.QuietStart
+	}
+	if request.QuietEnd != nil {
+		settings.QuietEnd = *request.QuietEnd
+	}
+	if request.Timezone != nil {
+		settings.Timezone = *request.Timezone
+	}
+	if request.Digest != nil {
+		settings.Digest = *request.Digest
+	}
+
+	if (settings.QuietStart == "") != (settings.QuietEnd == "") {
+		app.badRequestResponse(w, r, errInvalidQuietHours)
+		return
+	}
+
+	if err := app.store.NotificationSettings.Update(ctx, settings); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, settings); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Unsubscribe godoc
+//
+//	@Summary		Unsubscribe from emails
+//	@Description	Stop the emails an unsubscribe link is for, without signing in. The token comes from the link.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		UnsubscribePayload	true	"Token of the link"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/notifications/unsubscribe [post]
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	var request UnsubscribePayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.unsubscribes.Decode(request.Token)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	settings, err := app.store.NotificationSettings.Get(ctx, token.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	switch {
+	case token.Scope == unsubscribe.ScopeAll:
+		for typ := range settings.Deliveries {
+			stopEmails(settings, typ)
+		}
+		settings.Digest = store.DigestOff
+	case token.Scope == unsubscribeScopeDigest:
+		settings.Digest = store.DigestOff
+	case store.IsNotificationType(token.Scope):
+		stopEmails(settings, token.Scope)
+	default:
+		app.badRequestResponse(w, r, unsubscribe.ErrInvalid)
+		return
+	}
+
+	if err := app.store.NotificationSettings.Update(ctx, settings); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// stopEmails keeps the notifications of typ in the app only.
+func stopEmails(settings *store.NotificationSettings, typ string) {
+	switch settings.Delivery(typ) {
+	case store.DeliveryAll:
+		settings.Deliveries[typ] = store.DeliveryInApp
+	case store.DeliveryEmail:
+		settings.Deliveries[typ] = store.DeliveryNone
+	}
+}
+
+// unsubscribeURL is the page of the frontend that unsubscribes userID from
+// the emails of scope, sent along every email.
+func (app *application) unsubscribeURL(userID int64, scope string) string {
+	token := app.unsubscribes.Encode(unsubscribe.Token{UserID: userID, Scope: scope})
+	return fmt.Sprintf("%s/unsubscribe/%s", app.config.frontendURL, token)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
+)
+
+func TestStopEmails(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery string
+		want     string
+	}{
+		{"should keep in app notifications of emailed ones", store.DeliveryAll, store.DeliveryInApp},
+		{"should stop notifications only emailed", store.DeliveryEmail, store.DeliveryNone},
+		{"should leave in app notifications alone", store.DeliveryInApp, store.DeliveryInApp},
+		{"should leave stopped notifications alone", store.DeliveryNone, store.DeliveryNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := &store.NotificationSettings{
+				Deliveries: map[string]string{store.NotificationMention: tt.delivery},
+			}
+
+			stopEmails(settings, store.NotificationMention)
+
+			if got := settings.Deliveries[store.NotificationMention]; got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	cfg := config{
+		rateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 20,
+			TimeFrame:            time.Second * 5,
+			Enabled:              true,
+		},
+		addr: ":8080",
+	}
+
+	post := func(t *testing.T, app *application, token string) int {
+		t.Helper()
+
+		body, _ := json.Marshal(UnsubscribePayload{Token: token})
+		req, err := http.NewRequest(http.MethodPost, "/v1/notifications/unsubscribe", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mount(app)).Code
+	}
+
+	settingsOf := func(t *testing.T, app *application, userID int64) *store.NotificationSettings {
+		t.Helper()
+
+		settings, err := app.store.NotificationSettings.Get(context.Background(), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return settings
+	}
+
+	t.Run("should stop the emails of a notification type", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		token := app.unsubscribes.Encode(unsubscribe.Token{UserID: 1, Scope: store.NotificationMention})
+
+		checkResponseCode(t, http.StatusNoContent, post(t, app, token))
+
+		if got := settingsOf(t, app, 1).Delivery(store.NotificationMention); got != store.DeliveryInApp {
+			t.Errorf("got mentions delivered %s, want %s", got, store.DeliveryInApp)
+		}
+	})
+
+	t.Run("should stop the digest", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		_ = app.store.NotificationSettings.Update(context.Background(), &store.NotificationSettings{
+			UserID: 1, Timezone: "UTC", Digest: store.DigestDaily,
+		})
+		token := app.unsubscribes.Encode(unsubscribe.Token{UserID: 1, Scope: unsubscribeScopeDigest})
+
+		checkResponseCode(t, http.StatusNoContent, post(t, app, token))
+
+		settings := settingsOf(t, app, 1)
+		if settings.Digest != store.DigestOff {
+			t.Errorf("got digest %s, want %s", settings.Digest, store.DigestOff)
+		}
+		if got := settings.Delivery(store.NotificationMention); got != store.DeliveryAll {
+			t.Errorf("got mentions delivered %s, want them untouched", got)
+		}
+	})
+
+	t.Run("should stop every email", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		_ = app.store.NotificationSettings.Update(context.Background(), &store.NotificationSettings{
+			UserID:     1,
+			Deliveries: map[string]string{store.NotificationFollow: store.DeliveryEmail},
+			Timezone:   "UTC",
+			Digest:     store.DigestWeekly,
+		})
+		token := app.unsubscribes.Encode(unsubscribe.Token{UserID: 1, Scope: unsubscribe.ScopeAll})
+
+		checkResponseCode(t, http.StatusNoContent, post(t, app, token))
+
+		settings := settingsOf(t, app, 1)
+		for typ := range settings.Deliveries {
+			if settings.Email(typ) {
+				t.Errorf("got %s emailed, want no emails", typ)
+			}
+		}
+		if settings.Digest != store.DigestOff {
+			t.Errorf("got digest %s, want %s", settings.Digest, store.DigestOff)
+		}
+	})
+
+	t.Run("should only touch the user of the token", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		token := app.unsubscribes.Encode(unsubscribe.Token{UserID: 1, Scope: unsubscribe.ScopeAll})
+
+		checkResponseCode(t, http.StatusNoContent, post(t, app, token))
+
+		if got := settingsOf(t, app, 2).Delivery(store.NotificationMention); got != store.DeliveryAll {
+			t.Errorf("got mentions delivered %s to another user, want them untouched", got)
+		}
+	})
+
+	t.Run("should reject forged tokens", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		token := unsubscribe.NewSigner("guessed").Encode(unsubscribe.Token{UserID: 1, Scope: unsubscribe.ScopeAll})
+
+		checkResponseCode(t, http.StatusBadRequest, post(t, app, token))
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		token := app.unsubscribes.Encode(unsubscribe.Token{UserID: 1, Scope: "newsletter"})
+
+		checkResponseCode(t, http.StatusBadRequest, post(t, app, token))
+	})
+}
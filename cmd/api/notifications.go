@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/mailer"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
+)
+
+type UnreadCount struct {
+	Unread int `json:"unread"`
+}
+
+// ListNotifications godoc
+//
+//	@Summary		List your notifications
+//	@Description	List your notifications, latest activity first. Similar events are grouped while unread, like the new comments on a post. A notification with new activity moves back to the first page, the next pages leave it out.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only the unread notifications"
+//	@Param			cursor	query		string	false	"Cursor from next_cursor or prev_cursor"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.Notification
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	nq := store.NotificationsQuery{
+		Limit: 20,
+	}
+
+	nq, err := nq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	nq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(nq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	notifications, more, err := app.store.Notifications.List(r.Context(), user.ID, nq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, prev := app.pageCursors(nq.Cursor, 0, more, len(notifications), func(i int) (time.Time, int64) {
+		return notifications[i].UpdatedAt, notifications[i].ID
+	})
+
+	if err := app.jsonPageResponse(w, http.StatusOK, notifications, next, prev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUnreadNotificationsCount godoc
+//
+//	@Summary		Count your unread notifications
+//	@Description	Count your unread notifications, a group of events counts once
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	UnreadCount
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	count, err := app.unreadNotifications(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, UnreadCount{Unread: count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkNotificationRead godoc
+//
+//	@Summary		Mark a notification read
+//	@Description	Mark one of your notifications read, the next similar event starts a new one
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			notificationID	path		int	true	"Notification ID"
+//	@Success		204				{string}	string
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := getParamAsInt(r, "notificationID")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid notification id"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	if err := app.store.Notifications.MarkRead(r.Context(), user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.forgetUnreadNotifications(r.Context(), user.ID)
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkAllNotificationsRead godoc
+//
+//	@Summary		Mark all your notifications read
+//	@Description	Mark all your notifications read
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		204	{string}	string
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if err := app.store.Notifications.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.forgetUnreadNotifications(r.Context(), user.ID)
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// notify delivers a notification in the background the way the user chose:
+// to the inbox, pushed live when they are connected, and by email. Quiet
+// hours hold back the push and the email. Users aren't notified of what they
+// do themselves.
+func (app *application) notify(e store.NotificationEvent) {
+	if e.UserID == e.ActorID {
+		return
+	}
+
+	app.background(func() {
+		ctx := context.Background()
+
+		settings, err := app.store.NotificationSettings.Get(ctx, e.UserID)
+		if err != nil {
+			app.logger.Errorw("failed to load notification settings", "user_id", e.UserID, "error", err.Error())
+			return
+		}
+
+		now := time.Now()
+		quiet := settings.Quiet(now)
+
+		if settings.InApp(e.Type) {
+			id, created, err := app.store.Notifications.Add(ctx, e)
+			if err != nil {
+				app.logger.Errorw("failed to add notification", "user_id", e.UserID, "type", e.Type, "error", err.Error())
+				return
+			}
+
+			if created {
+				app.forgetUnreadNotifications(ctx, e.UserID)
+			}
+
+			if !quiet {
+				app.publishEvent(stream.UserTopic(e.UserID), streamEventNotification, streamNotification{
+					ID:        id,
+					Type:      e.Type,
+					PostID:    e.PostID,
+					CommentID: e.CommentID,
+					ActorID:   e.ActorID,
+				})
+			}
+		}
+
+		if settings.Email(e.Type) {
+			if err := app.emailOrHold(ctx, e, settings, now); err != nil {
+				app.logger.Errorw("error sending notification email", "user_id", e.UserID, "type", e.Type, "error", err.Error())
+			}
+		}
+	})
+}
+
+// emailOrHold emails a notification, or holds the email until the quiet hours
+// of the user are over.
+func (app *application) emailOrHold(ctx context.Context, e store.NotificationEvent, settings *store.NotificationSettings, now time.Time) error {
+	if settings.Quiet(now) {
+		return app.store.Notifications.HoldEmail(ctx, e, settings.QuietUntil(now))
+	}
+	return app.emailNotification(ctx, e)
+}
+
+// sendHeldEmails sends the notification emails held back by quiet hours that
+// are over. Settings changed in the meantime still apply.
+func (app *application) sendHeldEmails(ctx context.Context) error {
+	now := time.Now()
+
+	for {
+		events, err := app.store.Notifications.ReleaseHeldEmails(ctx, now, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			settings, err := app.store.NotificationSettings.Get(ctx, e.UserID)
+			if err != nil {
+				app.logger.Errorw("failed to load notification settings", "user_id", e.UserID, "error", err.Error())
+				continue
+			}
+
+			if !settings.Email(e.Type) {
+				continue
+			}
+
+			if err := app.emailOrHold(ctx, e, settings, now); err != nil {
+				app.logger.Errorw("error sending notification email", "user_id", e.UserID, "type", e.Type, "error", err.Error())
+			}
+		}
+
+		if len(events) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+// emailNotification emails a single notification, mentions keep their own
+// template.
+func (app *application) emailNotification(ctx context.Context, e store.NotificationEvent) error {
+	user, err := app.store.Users.GetByID(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+
+	actor, err := app.store.Users.GetByID(ctx, e.ActorID)
+	if err != nil {
+		return err
+	}
+
+	kind := "post"
+	if e.CommentID != nil {
+		kind = "comment"
+	}
+
+	vars := struct {
+		Username          string
+		ActorName         string
+		Action            string
+		Kind              string
+		URL               string
+		UnsubscribeURL    string
+		UnsubscribeAllURL string
+	}{
+		Username:          user.Username,
+		ActorName:         actor.Username,
+		Action:            store.NotificationAction(e.Type),
+		Kind:              kind,
+		URL:               app.notificationURL(e.Type, e.ActorID, e.PostID, e.CommentID),
+		UnsubscribeURL:    app.unsubscribeURL(user.ID, e.Type),
+		UnsubscribeAllURL: app.unsubscribeURL(user.ID, unsubscribe.ScopeAll),
+	}
+
+	template := mailer.NotificationTemplate
+	if e.Type == store.NotificationMention {
+		template = mailer.UserMentionTemplate
+	}
+
+	isProdEnv := app.config.env == "prod"
+	_, err = app.mailer.Send(template, user.Username, user.Email, vars, !isProdEnv)
+	return err
+}
+
+// notificationURL is the page of the frontend a notification leads to.
+func (app *application) notificationURL(typ string, actorID int64, postID, commentID *int64) string {
+	switch {
+	case typ == store.NotificationFollow || typ == store.NotificationFollowRequest:
+		return fmt.Sprintf("%s/users/%d", app.config.frontendURL, actorID)
+	case postID != nil && commentID != nil:
+		return fmt.Sprintf("%s/posts/%d#comment-%d", app.config.frontendURL, *postID, *commentID)
+	case postID != nil:
+		return fmt.Sprintf("%s/posts/%d", app.config.frontendURL, *postID)
+	default:
+		return fmt.Sprintf("%s/notifications", app.config.frontendURL)
+	}
+}
+
+// notifyComment tells the author of a post about a new comment on it, or the
+// author of the comment replied to.
+func (app *application) notifyComment(post *store.Post, comment *store.Comment) {
+	if comment.ParentID == nil {
+		app.notify(store.NotificationEvent{
+			UserID:    post.UserID,
+			ActorID:   comment.UserID,
+			Type:      store.NotificationComment,
+			PostID:    &post.ID,
+			CommentID: &comment.ID,
+		})
+		return
+	}
+
+	parentID := *comment.ParentID
+	app.background(func() {
+		parent, err := app.store.Comments.GetByID(context.Background(), parentID)
+		if err != nil {
+			app.logger.Errorw("failed to load parent comment", "comment_id", parentID, "error", err.Error())
+			return
+		}
+
+		app.notify(store.NotificationEvent{
+			UserID:    parent.UserID,
+			ActorID:   comment.UserID,
+			Type:      store.NotificationReply,
+			PostID:    &post.ID,
+			CommentID: &parent.ID,
+		})
+
+		// the author of the post hears about replies to others
+		if parent.UserID != post.UserID {
+			app.notify(store.NotificationEvent{
+				UserID:    post.UserID,
+				ActorID:   comment.UserID,
+				Type:      store.NotificationComment,
+				PostID:    &post.ID,
+				CommentID: &comment.ID,
+			})
+		}
+	})
+}
+
+// unreadNotifications counts the unread notifications of a user, from the
+// cache when it has them. The cache failing only costs a count.
+func (app *application) unreadNotifications(ctx context.Context, userID int64) (int, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Notifications.CountUnread(ctx, userID)
+	}
+
+	count, ok, err := app.cacheStore.Notifications.GetUnread(ctx, userID)
+	if err != nil {
+		app.logger.Errorw("failed to read unread notifications count", "user_id", userID, "error", err.Error())
+		return app.store.Notifications.CountUnread(ctx, userID)
+	}
+
+	if ok {
+		return count, nil
+	}
+
+	count, err = app.store.Notifications.CountUnread(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := app.cacheStore.Notifications.SetUnread(ctx, userID, count); err != nil {
+		app.logger.Errorw("failed to cache unread notifications count", "user_id", userID, "error", err.Error())
+	}
+
+	return count, nil
+}
+
+func (app *application) forgetUnreadNotifications(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStore.Notifications.DeleteUnread(ctx, userID); err != nil {
+		app.logger.Errorw("failed to drop unread notifications count", "user_id", userID, "error", err.Error())
+	}
+}
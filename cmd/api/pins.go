@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// PinPost godoc
+//
+//	@Summary		Pin a post
+//	@Description	Pin one of your published posts to your profile, up to 3 posts can be pinned
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{string}	string
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [put]
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("you can only pin your own posts"))
+		return
+	}
+
+	if err := app.store.Posts.Pin(r.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, errors.New("only published posts can be pinned"))
+		case errors.Is(err, store.ErrTooManyPinned):
+			app.conflicResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notContent(w)
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpin a post
+//	@Description	Remove one of your posts from the pinned posts of your profile, 404 when it isn't pinned
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{string}	string
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [delete]
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("you can only unpin your own posts"))
+		return
+	}
+
+	if err := app.store.Posts.Unpin(r.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notContent(w)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// maxPollDuration is how long a poll can stay open.
+const maxPollDuration = 30 * 24 * time.Hour
+
+type CreatePollPayload struct {
+	Options               []string  `json:"options" validate:"required,min=2,max=6,dive,required,max=100"`
+	MultipleChoice        bool      `json:"multiple_choice"`
+	ExpiresAt             time.Time `json:"expires_at" validate:"required"`
+	HideResultsUntilVoted bool      `json:"hide_results_until_voted"`
+}
+
+type PollVotePayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=6,unique,dive,gte=1"`
+}
+
+// newPoll builds the poll of a new post. It runs after the payload is
+// validated, so only the checks the validator can't express are left.
+func newPoll(request *CreatePollPayload) (*store.Poll, error) {
+	now := time.Now()
+	if !request.ExpiresAt.After(now) {
+		return nil, errors.New("poll expires_at must be in the future")
+	}
+
+	if request.ExpiresAt.After(now.Add(maxPollDuration)) {
+		return nil, errors.New("polls can't stay open for more than 30 days")
+	}
+
+	poll := &store.Poll{
+		MultipleChoice: request.MultipleChoice,
+		HideResults:    request.HideResultsUntilVoted,
+		ExpiresAt:      request.ExpiresAt,
+		Options:        make([]store.PollOption, len(request.Options)),
+	}
+
+	seen := make(map[string]bool, len(request.Options))
+	for i, text := range request.Options {
+		if seen[text] {
+			return nil, errors.New("poll options must be unique")
+		}
+		seen[text] = true
+		poll.Options[i].Text = text
+	}
+
+	return poll, nil
+}
+
+// VotePoll godoc
+//
+//	@Summary		Vote on the poll of a post
+//	@Description	Vote on the poll of a post, voting again replaces the previous vote until the poll closes
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			request	body		PollVotePayload	true	"Options"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [put]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var request PollVotePayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromContext(r)
+	if post.Status != store.PostStatusPublished {
+		app.notFoundResponse(w, r, store.ErrorNotFound)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	poll, err := app.store.Polls.GetByPostID(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if poll == nil {
+		app.notFoundResponse(w, r, errors.New("the post has no poll"))
+		return
+	}
+
+	if err := app.store.Polls.Vote(ctx, poll.ID, user.ID, request.OptionIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrPollClosed):
+			app.conflicResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidPollVote):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notify(store.NotificationEvent{
+		UserID:  post.UserID,
+		ActorID: user.ID,
+		Type:    store.NotificationReaction,
+		PostID:  &post.ID,
+	})
+
+	poll, err = app.store.Polls.GetByPostID(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, poll); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
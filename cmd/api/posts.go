@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"ontopsolutions.net/gasperlf/social/internal/store"
@@ -15,20 +17,29 @@ type postKey string
 const contextKeyPost postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title          string              `json:"title" validate:"required,max=100"`
+	Content        string              `json:"content" validate:"required,max=1000"`
+	Tags           []string            `json:"tags"`
+	Status         string              `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt      *time.Time          `json:"publish_at" validate:"required_if=Status scheduled"`
+	Visibility     string              `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	Language       string              `json:"language" validate:"omitempty,oneof=simple english spanish portuguese french italian german"`
+	ContentWarning string              `json:"content_warning" validate:"max=200"`
+	Sensitive      bool                `json:"sensitive"`
+	ExpiresAt      *time.Time          `json:"expires_at"`
+	Poll           *CreatePollPayload  `json:"poll"`
+	Attachments    []AttachmentPayload `json:"attachments" validate:"max=4,dive"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string   `json:"title" validate:"required,max=100"`
-	Content *string   `json:"content" validate:"required,max=1000"`
-	Tags    *[]string `json:"tags"`
-}
-
-type CreateCommentPayload struct {
-	Content string `json:"content" validate:"required,max=500"`
-	UserID  int64  `json:"user_id" validate:"required"`
+	Title          *string    `json:"title" validate:"required,max=100"`
+	Content        *string    `json:"content" validate:"required,max=1000"`
+	Tags           *[]string  `json:"tags"`
+	Status         *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt      *time.Time `json:"publish_at"`
+	Visibility     *string    `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	ContentWarning *string    `json:"content_warning" validate:"omitempty,max=200"`
+	Sensitive      *bool      `json:"sensitive"`
 }
 
 // CreatePost godoc
@@ -60,19 +71,59 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 	user := getUserFromContext(r)
 	post := &store.Post{
-		Title:   request.Title,
-		Content: request.Content,
-		Tags:    request.Tags,
-		UserID:  user.ID,
+		Title:          request.Title,
+		Content:        request.Content,
+		Tags:           request.Tags,
+		UserID:         user.ID,
+		Visibility:     request.Visibility,
+		Language:       request.Language,
+		ContentWarning: request.ContentWarning,
+		Sensitive:      request.Sensitive,
+	}
+
+	if err := setPostStatus(post, request.Status, request.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if request.ExpiresAt != nil {
+		if err := app.checkPostExpiry(post, *request.ExpiresAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		post.ExpiresAt = request.ExpiresAt
+	}
+
+	for _, a := range request.Attachments {
+		post.Attachments = append(post.Attachments, store.Attachment{ID: a.ID, AltText: a.AltText})
+	}
+
+	if request.Poll != nil {
+		poll, err := newPoll(request.Poll)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		post.Poll = poll
 	}
 
 	ctx := r.Context()
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrInvalidAttachment):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.notifyPostMentions(post)
+	app.fanOutPost(post)
+	app.streamPost(post)
+	app.indexPosts(post.ID)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -97,13 +148,33 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post := getPostFromContext(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	cq := store.CommentPaginationQuery{
+		Limit: 20,
+	}
+	comments, _, err := app.store.Comments.GetByPostID(r.Context(), post.ID, cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
 
+	poll, err := app.store.Polls.GetByPostID(r.Context(), post.ID, viewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Poll = poll
+
+	// a post opened directly is never hidden, at most collapsed
+	prefs, err := app.store.Preferences.Get(r.Context(), viewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	store.ApplyDisplay(prefs, post)
+
+	app.recordViews(r, post)
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -113,34 +184,25 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // DeletePost godoc
 //
 //	@Summary		Delete a post
-//	@Description	Delete a post by ID
+//	@Description	Move a post to the trash of its author, allowed for the author and admins. Posts deleted by an admin can't be restored by their author
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
 //	@Param			postID	path		int	true	"Post ID"
-//	@Success		200		{string}	string
+//	@Success		204		{string}	string
 //	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/posts [delete]
+//	@Router			/posts/{postID} [delete]
 func (app *application) DeletePostHandler(w http.ResponseWriter, r *http.Request) {
 
-	postIDParam := chi.URLParam(r, "postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
+	post := getPostFromContext(r)
+	user := getUserFromContext(r)
 
 	ctx := r.Context()
-	err = app.store.Comments.DeleteByPostID(ctx, postID)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-	err = app.store.Posts.Delete(ctx, postID)
+	err := app.store.Posts.Delete(ctx, post.ID, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrorNotFound):
@@ -151,6 +213,8 @@ func (app *application) DeletePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.indexPosts(post.ID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.notContent(w)
 		return
@@ -169,6 +233,7 @@ func (app *application) DeletePostHandler(w http.ResponseWriter, r *http.Request
 //	@Param			request	body		UpdatePostPayload	true	"query params"
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -187,67 +252,68 @@ func (app *application) UpdatePostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	post := getPostFromContext(r)
+	wasPublished := post.Status == store.PostStatusPublished
 	post.Title = *request.Title
 	post.Content = *request.Content
 	if request.Tags != nil {
 		post.Tags = *request.Tags
 	}
-
-	ctx := r.Context()
-	updatedPost, err := app.store.Posts.Update(ctx, post)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+	if request.Visibility != nil {
+		post.Visibility = *request.Visibility
 	}
-
-	if err := app.jsonResponse(w, http.StatusOK, updatedPost); err != nil {
-		app.internalServerError(w, r, err)
-		return
+	if changesWarning(post, request) {
+		if post.WarningForced {
+			app.forbiddenErrorResponse(w, r, errors.New("the content warning was set by a moderator and can't be changed"))
+			return
+		}
+		if request.ContentWarning != nil {
+			post.ContentWarning = *request.ContentWarning
+		}
+		if request.Sensitive != nil {
+			post.Sensitive = *request.Sensitive
+		}
 	}
-}
-
-// CreatePost godoc
-//
-//	@Summary		Create a comments for a post
-//	@Description	Create a comments for a post by ID
-//	@Tags			posts
-//	@Accept			json
-//	@Produce		json
-//	@Param			postID	path		int						true	"Post ID"
-//	@Param			request	body		CreateCommentPayload	true	"query params"
-//	@Success		200		{object}	store.Comment
-//	@Failure		400		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
-//	@Security		ApiKeyAuth
-//	@Router			/posts/postID/comments [post]
-func (app *application) createCommentPostHandler(w http.ResponseWriter, r *http.Request) {
-
-	var request CreateCommentPayload
-	if err := readJSON(w, r, &request); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
+	if request.Status != nil {
+		if err := setPostStatus(post, *request.Status, request.PublishAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	} else if request.PublishAt != nil {
+		// a publish_at alone reschedules the post
+		if post.Status != store.PostStatusScheduled {
+			app.badRequestResponse(w, r, errors.New("publish_at needs status scheduled unless the post is already scheduled"))
+			return
+		}
+		if err := setPostStatus(post, store.PostStatusScheduled, request.PublishAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
 	}
 
-	if err := Validate.Struct(request); err != nil {
-		app.badRequestResponse(w, r, err)
+	user := getUserFromContext(r)
+	ctx := r.Context()
+	updatedPost, err := app.store.Posts.Update(ctx, post, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflicResponse(w, r, errors.New("the post was modified by someone else, reload it and try again"))
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	post := getPostFromContext(r)
-	comment := &store.Comment{
-		PostID:  post.ID,
-		Content: request.Content,
-		UserID:  request.UserID,
+	if updatedPost.UserID == user.ID {
+		app.notifyPostMentions(updatedPost)
 	}
 
-	ctx := r.Context()
-	if err := app.store.Comments.Create(ctx, comment); err != nil {
-		app.internalServerError(w, r, err)
-		return
+	if !wasPublished {
+		app.fanOutPost(updatedPost)
+		app.streamPost(updatedPost)
 	}
+	app.indexPosts(updatedPost.ID)
 
-	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, updatedPost); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -276,11 +342,64 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// every handler below, reading the post or commenting on it,
+		// relies on this check. Posts the viewer can't see don't exist
+		// for them.
+		visible, err := app.store.Posts.CanView(ctx, viewerID(r), post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrorNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, contextKeyPost, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// setPostStatus applies the requested publication status, a scheduled post
+// needs a publish time in the future.
+func setPostStatus(post *store.Post, status string, publishAt *time.Time) error {
+	switch status {
+	case "", store.PostStatusPublished:
+		post.Status = store.PostStatusPublished
+		post.PublishAt = nil
+	case store.PostStatusDraft:
+		post.Status = store.PostStatusDraft
+		post.PublishAt = nil
+	case store.PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return errors.New("publish_at must be in the future for scheduled posts")
+		}
+		post.Status = store.PostStatusScheduled
+		post.PublishAt = publishAt
+	default:
+		return fmt.Errorf("invalid post status %q", status)
+	}
+
+	return nil
+}
+
+// changesWarning reports whether the update touches the content warning or
+// the sensitive flag of post.
+func changesWarning(post *store.Post, request UpdatePostPayload) bool {
+	return (request.ContentWarning != nil && *request.ContentWarning != post.ContentWarning) ||
+		(request.Sensitive != nil && *request.Sensitive != post.Sensitive)
+}
+
+// viewerID is the authenticated user of the request, 0 for anonymous requests.
+func viewerID(r *http.Request) int64 {
+	user := getUserFromContext(r)
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
 func getPostFromContext(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(contextKeyPost).(*store.Post)
 	return post
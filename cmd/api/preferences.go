@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+)
+
+type UpdatePreferencesPayload struct {
+	FlaggedContent *string `json:"flagged_content" validate:"omitempty,oneof=warn expand hide"`
+}
+
+// GetPreferences godoc
+//
+//	@Summary		Get your preferences
+//	@Description	Get the display preferences of the authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	store.Preferences
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/preferences [get]
+func (app *application) getPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	prefs, err := app.store.Preferences.Get(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdatePreferences godoc
+//
+//	@Summary		Update your preferences
+//	@Description	Choose how posts with a content warning or marked sensitive show up: collapsed (warn), expanded (expand) or left out of feeds (hide)
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		UpdatePreferencesPayload	true	"Preferences"
+//	@Success		200		{object}	store.Preferences
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/preferences [patch]
+func (app *application) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdatePreferencesPayload
+	if err := readJSON(w, r, &request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(request); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	prefs, err := app.store.Preferences.Get(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if request.FlaggedContent != nil {
+		prefs.FlaggedContent = *request.FlaggedContent
+	}
+
+	if err := app.store.Preferences.Update(ctx, prefs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// previewFetchers bounds how many pages are fetched at once.
+const previewFetchers = 4
+
+// fetchLinkPreviews builds the preview cards of the links queued by new and
+// edited posts.
+func (app *application) fetchLinkPreviews(ctx context.Context) error {
+	for {
+		urls, err := app.store.LinkPreviews.ClaimPending(ctx, app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		queue := make(chan string)
+		var wg sync.WaitGroup
+		for range previewFetchers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for url := range queue {
+					app.fetchLinkPreview(ctx, url)
+				}
+			}()
+		}
+
+		for _, url := range urls {
+			queue <- url
+		}
+		close(queue)
+		wg.Wait()
+
+		if len(urls) == 0 || len(urls) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+func (app *application) fetchLinkPreview(ctx context.Context, url string) {
+	card, err := app.previews.Fetch(ctx, url)
+	if err != nil {
+		// broken and private links are expected, they just get no card
+		app.logger.Infow("link preview unavailable", "url", url, "error", err.Error())
+
+		if err := app.store.LinkPreviews.MarkFailed(ctx, url); err != nil {
+			app.logger.Errorw("failed to save link preview", "url", url, "error", err.Error())
+		}
+		return
+	}
+
+	preview := &store.LinkPreview{
+		URL:         url,
+		Title:       card.Title,
+		Description: card.Description,
+		ImageURL:    card.ImageURL,
+		SiteName:    card.SiteName,
+	}
+
+	if err := app.store.LinkPreviews.Save(ctx, preview); err != nil {
+		app.logger.Errorw("failed to save link preview", "url", url, "error", err.Error())
+	}
+}
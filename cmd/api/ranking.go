@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+var (
+	errInvalidFeedMode = errors.New("mode must be chronological or ranked")
+	errRankedCursor    = errors.New("the ranked feed is paginated with offset, not cursor")
+)
+
+const (
+	feedModeChronological = "chronological"
+	feedModeRanked        = "ranked"
+)
+
+// RankedPost is a post of the ranked feed, with the breakdown of its score
+// when the feed was asked for in debug mode.
+type RankedPost struct {
+	store.PostWithMetadata
+	Ranking *ranking.Explanation `json:"ranking,omitempty"`
+}
+
+// rankedFeed serves the ranked feed of user. The candidates are ranked again
+// on every request and paged with the offset, the order shifts a little as
+// posts get older so there are no cursors.
+func (app *application) rankedFeed(w http.ResponseWriter, r *http.Request, user *store.User, fq store.PaginationFeedQuery) {
+	if fq.Cursor != nil {
+		app.badRequestResponse(w, r, errRankedCursor)
+		return
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+
+	candidates, err := app.store.Posts.GetRankingCandidates(ctx, user.ID, now.Add(-app.config.ranking.window), app.config.ranking.candidates)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ranked := ranking.Rank(app.scorer, now, candidates, app.config.ranking.maxConsecutive)
+
+	start := min(fq.Offset, len(ranked))
+	end := min(start+fq.Limit, len(ranked))
+	page := ranked[start:end]
+
+	ids := make([]int64, len(page))
+	for i, p := range page {
+		ids[i] = p.PostID
+	}
+
+	posts, err := app.store.Posts.GetByIDs(ctx, user.ID, ids)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordFeedViews(r, posts)
+
+	explanations := make(map[int64]ranking.Explanation, len(page))
+	for _, p := range page {
+		explanations[p.PostID] = p.Explanation
+	}
+
+	debug := app.config.env != "prod" && r.URL.Query().Get("debug") == "true"
+
+	feed := make([]RankedPost, len(posts))
+	for i, p := range posts {
+		feed[i] = RankedPost{PostWithMetadata: p}
+		if debug {
+			e := explanations[p.ID]
+			feed[i].Ranking = &e
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"ontopsolutions.net/gasperlf/social/internal/diff"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+type PostRevisionDiff struct {
+	PostID      int64       `json:"post_id"`
+	From        int         `json:"from"`
+	To          int         `json:"to"`
+	Title       []diff.Edit `json:"title"`
+	Content     []diff.Edit `json:"content"`
+	TagsAdded   []string    `json:"tags_added"`
+	TagsRemoved []string    `json:"tags_removed"`
+}
+
+// ListPostRevisions godoc
+//
+//	@Summary		List the revisions of a post
+//	@Description	List every version of a post, newest first. Only the author and moderators see them
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) listPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromContext(r)
+
+	revisions, err := app.store.PostRevisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Diff two revisions of a post
+//	@Description	Show the changes to title, content and tags between two versions of a post. Only the author and moderators see them
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Base version"
+//	@Param			to		query		int	true	"Target version"
+//	@Success		200		{object}	PostRevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	from, err := strconv.Atoi(qs.Get("from"))
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid from version"))
+		return
+	}
+
+	to, err := strconv.Atoi(qs.Get("to"))
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid to version"))
+		return
+	}
+
+	post := getPostFromContext(r)
+	ctx := r.Context()
+
+	base, err := app.store.PostRevisions.GetByVersion(ctx, post.ID, from)
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+
+	target, err := app.store.PostRevisions.GetByVersion(ctx, post.ID, to)
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+
+	response := PostRevisionDiff{
+		PostID:      post.ID,
+		From:        from,
+		To:          to,
+		Title:       diff.Words(base.Title, target.Title),
+		Content:     diff.Lines(base.Content, target.Content),
+		TagsAdded:   missingTags(target.Tags, base.Tags),
+		TagsRemoved: missingTags(base.Tags, target.Tags),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePostRevision godoc
+//
+//	@Summary		Restore a revision of a post
+//	@Description	Restore title, content and tags of an earlier version, saved as a new version
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version to restore"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *application) restorePostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := getParamAsInt(r, "version")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid version"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+	ctx := r.Context()
+
+	revision, err := app.store.PostRevisions.GetByVersion(ctx, post.ID, int(version))
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = revision.Tags
+
+	restored, err := app.store.Posts.Update(ctx, post, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflicResponse(w, r, errors.New("the post was modified by someone else, reload it and try again"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPosts(restored.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, restored); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) revisionLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrorNotFound):
+		app.notFoundResponse(w, r, errors.New("revision not found"))
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// missingTags returns the tags of a that are not in b.
+func missingTags(a, b []string) []string {
+	missing := []string{}
+	for _, tag := range a {
+		if !slices.Contains(b, tag) {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
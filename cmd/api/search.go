@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+	"ontopsolutions.net/gasperlf/social/internal/search"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+const (
+	searchTypePosts    = "posts"
+	searchTypeComments = "comments"
+	searchTypeUsers    = "users"
+)
+
+type searchParams struct {
+	Q        string `validate:"required,max=200"`
+	Type     string `validate:"oneof=posts comments users"`
+	Language string `validate:"oneof=simple english spanish portuguese french italian german"`
+	Tag      string `validate:"max=50"`
+	Author   string `validate:"max=100"`
+	Limit    int    `validate:"gte=1,lte=20"`
+	Offset   int    `validate:"gte=0"`
+}
+
+// Search godoc
+//
+//	@Summary		Search posts, comments or users
+//	@Description	Full-text search ranked by relevance. Words are all required, "quoted words" are a phrase, a trailing * matches prefixes and a leading - excludes a word. With the bleve backend, words match with typos and post results come with facets by tag and author
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Query"
+//	@Param			type	query		string	false	"posts (default), comments or users"
+//	@Param			lang	query		string	false	"Language the query is stemmed in, english by default"
+//	@Param			tag		query		string	false	"Only posts with this tag"
+//	@Param			author	query		string	false	"Only posts by this username"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.PostSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	params := searchParams{
+		Q:        qs.Get("q"),
+		Type:     qs.Get("type"),
+		Language: qs.Get("lang"),
+		Tag:      qs.Get("tag"),
+		Author:   qs.Get("author"),
+		Limit:    20,
+	}
+
+	if params.Type == "" {
+		params.Type = searchTypePosts
+	}
+
+	if params.Language == "" {
+		params.Language = store.DefaultLanguage
+	}
+
+	if l := qs.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("limit must be a number"))
+			return
+		}
+		params.Limit = n
+	}
+
+	if o := qs.Get("offset"); o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("offset must be a number"))
+			return
+		}
+		params.Offset = n
+	}
+
+	if err := Validate.Struct(params); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// tags are stored normalized, #Go finds the posts tagged go
+	if params.Tag != "" {
+		params.Tag = entities.NormalizeTag(params.Tag)
+		if params.Tag == "" {
+			app.badRequestResponse(w, r, errors.New("invalid tag"))
+			return
+		}
+	}
+
+	query, err := search.Parse(params.Q)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if app.searchIndex != nil && params.Type != searchTypeComments {
+		app.searchIndexHandler(w, r, query, params)
+		return
+	}
+
+	sq := store.SearchQuery{
+		Query:    query.TSQuery(),
+		Language: params.Language,
+		Tag:      params.Tag,
+		Author:   params.Author,
+		Limit:    params.Limit,
+		Offset:   params.Offset,
+	}
+
+	ctx := r.Context()
+	viewer := viewerID(r)
+
+	var results any
+	switch params.Type {
+	case searchTypePosts:
+		posts, err := app.store.Search.Posts(ctx, viewer, sq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		found := make([]*store.Post, len(posts))
+		for i := range posts {
+			found[i] = &posts[i].Post
+		}
+		app.recordViews(r, found...)
+
+		results = posts
+	case searchTypeComments:
+		results, err = app.store.Search.Comments(ctx, viewer, sq)
+	case searchTypeUsers:
+		results, err = app.store.Search.Users(ctx, sq)
+	}
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// searchIndexHandler answers a search from the search index, comments are
+// only searched in Postgres.
+func (app *application) searchIndexHandler(w http.ResponseWriter, r *http.Request, query search.Query, params searchParams) {
+	if params.Type == searchTypeUsers {
+		users, err := app.searchUsersIndex(r, query, params)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, facets, err := app.searchPostsIndex(r, query, params)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	found := make([]*store.Post, len(posts))
+	for i := range posts {
+		found[i] = &posts[i].Post
+	}
+	app.recordViews(r, found...)
+
+	if err := app.jsonFacetsResponse(w, http.StatusOK, posts, facets); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"ontopsolutions.net/gasperlf/social/internal/search"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+const (
+	searchBackendPostgres = "postgres"
+	searchBackendBleve    = "bleve"
+)
+
+// indexPosts brings the posts up to date in the search index in the
+// background, the ones that are not searchable anymore are removed from it.
+// Postgres keeps its own search columns, there is nothing to do without an
+// index.
+func (app *application) indexPosts(ids ...int64) {
+	if app.searchIndex == nil || len(ids) == 0 {
+		return
+	}
+
+	app.background(func() {
+		ctx := context.Background()
+
+		docs, err := app.store.Posts.GetSearchDocuments(ctx, ids)
+		if err != nil {
+			app.logger.Errorw("failed to load posts to index", "post_ids", ids, "error", err.Error())
+			return
+		}
+
+		if err := app.searchIndex.IndexPosts(ctx, docs...); err != nil {
+			app.logger.Errorw("failed to index posts", "post_ids", ids, "error", err.Error())
+			return
+		}
+
+		indexed := make([]int64, len(docs))
+		for i, d := range docs {
+			indexed[i] = d.ID
+		}
+
+		gone := missingIDs(ids, indexed)
+		if err := app.searchIndex.DeletePosts(ctx, gone...); err != nil {
+			app.logger.Errorw("failed to remove posts from the index", "post_ids", gone, "error", err.Error())
+		}
+	})
+}
+
+// indexUsers is indexPosts for users, only active users are searchable.
+func (app *application) indexUsers(ids ...int64) {
+	if app.searchIndex == nil || len(ids) == 0 {
+		return
+	}
+
+	app.background(func() {
+		ctx := context.Background()
+
+		docs, err := app.store.Users.GetSearchDocuments(ctx, ids)
+		if err != nil {
+			app.logger.Errorw("failed to load users to index", "user_ids", ids, "error", err.Error())
+			return
+		}
+
+		if err := app.searchIndex.IndexUsers(ctx, docs...); err != nil {
+			app.logger.Errorw("failed to index users", "user_ids", ids, "error", err.Error())
+			return
+		}
+
+		indexed := make([]int64, len(docs))
+		for i, d := range docs {
+			indexed[i] = d.ID
+		}
+
+		gone := missingIDs(ids, indexed)
+		if err := app.searchIndex.DeleteUsers(ctx, gone...); err != nil {
+			app.logger.Errorw("failed to remove users from the index", "user_ids", gone, "error", err.Error())
+		}
+	})
+}
+
+// missingIDs returns the ids that are not in found.
+func missingIDs(ids, found []int64) []int64 {
+	missing := []int64{}
+	for _, id := range ids {
+		if !slices.Contains(found, id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// searchPostsIndex searches posts in the index and loads them from the store,
+// which drops anything the index is behind on. The index leaves out what the
+// viewer can't see or chose to hide, so the total and the facets only count
+// those stale posts too.
+func (app *application) searchPostsIndex(r *http.Request, query search.Query, params searchParams) ([]store.PostSearchResult, *search.Facets, error) {
+	ctx := r.Context()
+	viewer := search.Viewer{ID: viewerID(r)}
+
+	if viewer.ID != 0 {
+		following, err := app.store.Followers.GetFollowing(ctx, viewer.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, f := range following {
+			viewer.Following = append(viewer.Following, f.UserID)
+		}
+
+		prefs, err := app.store.Preferences.Get(ctx, viewer.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		viewer.HideFlagged = prefs.FlaggedContent == store.FlaggedContentHide
+	}
+
+	res, err := app.searchIndex.SearchPosts(ctx, search.PostQuery{
+		Query:  query,
+		Viewer: viewer,
+		Tag:    params.Tag,
+		Author: params.Author,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]int64, len(res.Hits))
+	hits := make(map[int64]search.Hit, len(res.Hits))
+	for i, h := range res.Hits {
+		ids[i] = h.ID
+		hits[h.ID] = h
+	}
+
+	posts, err := app.store.Posts.GetByIDs(ctx, viewer.ID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]store.PostSearchResult, len(posts))
+	for i, p := range posts {
+		hit := hits[p.ID]
+		results[i] = store.PostSearchResult{PostWithMetadata: p, Rank: hit.Score, Snippet: hit.Snippet}
+	}
+
+	return results, &res.Facets, nil
+}
+
+// searchUsersIndex searches users in the index, keeping the ones still
+// active.
+func (app *application) searchUsersIndex(r *http.Request, query search.Query, params searchParams) ([]store.UserSearchResult, error) {
+	ctx := r.Context()
+
+	res, err := app.searchIndex.SearchUsers(ctx, search.UserQuery{
+		Query:  query,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(res.Hits))
+	for i, h := range res.Hits {
+		ids[i] = h.ID
+	}
+
+	docs, err := app.store.Users.GetSearchDocuments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[int64]string, len(docs))
+	for _, d := range docs {
+		usernames[d.ID] = d.Username
+	}
+
+	results := []store.UserSearchResult{}
+	for _, h := range res.Hits {
+		if username, ok := usernames[h.ID]; ok {
+			results = append(results, store.UserSearchResult{ID: h.ID, Username: username, Rank: h.Score})
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+)
+
+const (
+	streamEventPost         = "post"
+	streamEventComment      = "comment"
+	streamEventNotification = "notification"
+	// streamEventReset tells the client that events were missed and it has
+	// to reload what it shows
+	streamEventReset = "reset"
+)
+
+type streamPost struct {
+	PostID    int64     `json:"post_id"`
+	UserID    int64     `json:"user_id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type streamComment struct {
+	CommentID int64     `json:"comment_id"`
+	PostID    int64     `json:"post_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type streamNotification struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	PostID    *int64 `json:"post_id,omitempty"`
+	CommentID *int64 `json:"comment_id,omitempty"`
+	ActorID   int64  `json:"actor_id"`
+}
+
+// Stream godoc
+//
+//	@Summary		Stream live events
+//	@Description	Server-sent events with the new posts of followed users, the new comments on your posts and your notifications. Send Last-Event-ID to resume after a disconnect, a reset event means some events were missed.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		int	false	"ID of the last event received"
+//	@Success		200				{string}	string
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	lastID, err := lastEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	topics, err := app.streamTopics(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the connection outlives the write timeout of the server, every write
+	// gets a deadline of its own instead so a client that stopped reading
+	// doesn't hold the handler forever. The heartbeat keeps extending it.
+	rc := http.NewResponseController(w)
+	extendDeadline := func() error {
+		err := rc.SetWriteDeadline(time.Now().Add(app.config.stream.writeWait))
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	if err := extendDeadline(); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sub, missed, complete := app.hub.Subscribe(topics, lastID)
+	defer app.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", app.config.stream.retry.Milliseconds())
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamEventReset)
+	}
+	for _, e := range missed {
+		writeStreamEvent(w, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events:
+			if !ok {
+				// dropped for falling behind or shutting down, the client
+				// reconnects and resumes
+				return
+			}
+			if err := extendDeadline(); err != nil {
+				return
+			}
+			writeStreamEvent(w, e)
+		case <-heartbeat.C:
+			if err := extendDeadline(); err != nil {
+				return
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
+
+// lastEventID reads the event to resume from, browsers send it as a header
+// and clients that can't set headers as a query param.
+func lastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid Last-Event-ID")
+	}
+
+	return id, nil
+}
+
+// streamTopics lists the topics a user gets: their own and the posts of the
+// users they follow.
+func (app *application) streamTopics(ctx context.Context, userID int64) ([]string, error) {
+	following, err := app.store.Followers.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := []string{stream.UserTopic(userID)}
+	for _, f := range following {
+		topics = append(topics, stream.AuthorTopic(f.UserID))
+	}
+
+	return topics, nil
+}
+
+// eventsMaxBackoff caps the wait between attempts to get the events of the
+// other instances again.
+const eventsMaxBackoff = time.Second * 30
+
+// runEvents delivers the events published by every instance to the local hub
+// until ctx is done. When the subscription fails it is retried with backoff,
+// clients resuming across the gap are reset.
+func (app *application) runEvents(ctx context.Context) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := app.events.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("subscription closed")
+		}
+
+		// a subscription that held for a while starts over from the
+		// shortest wait
+		if time.Since(started) > eventsMaxBackoff {
+			backoff = time.Second
+		}
+
+		app.logger.Errorw("event stream stopped, retrying", "error", err.Error(), "backoff", backoff.String())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, eventsMaxBackoff)
+	}
+}
+
+// publishEvent publishes in the background, publishing may go over the
+// network and a lost event is caught up by a reset.
+func (app *application) publishEvent(topic, typ string, data any) {
+	app.background(func() {
+		if err := app.events.Publish(context.Background(), topic, typ, data); err != nil {
+			app.logger.Errorw("failed to publish event", "topic", topic, "type", typ, "error", err.Error())
+		}
+	})
+}
+
+// streamPost pushes a published post to the followers of its author that are
+// connected. Posts for mentioned users only are left out, their followers
+// may not see them.
+func (app *application) streamPost(post *store.Post) {
+	if post.Status != store.PostStatusPublished || post.Visibility == store.VisibilityMentioned {
+		return
+	}
+
+	app.publishEvent(stream.AuthorTopic(post.UserID), streamEventPost, streamPost{
+		PostID:    post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		CreatedAt: post.CreatedAt,
+	})
+}
+
+// streamComment pushes a new comment to the clients watching its post, and
+// tells the author of the post about it.
+func (app *application) streamComment(post *store.Post, comment *store.Comment, author string) {
+	data := streamComment{
+		CommentID: comment.ID,
+		PostID:    post.ID,
+		UserID:    comment.UserID,
+		Username:  author,
+		CreatedAt: comment.CreatedAt,
+	}
+
+	app.publishEvent(stream.PostTopic(post.ID), streamEventComment, data)
+	if comment.UserID != post.UserID {
+		app.publishEvent(stream.UserTopic(post.UserID), streamEventComment, data)
+	}
+}
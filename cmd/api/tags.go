@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// trendingWindows are the sliding windows trending tags can be computed on.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  time.Hour * 24 * 7,
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		List the posts of a tag
+//	@Description	List the published posts tagged with a tag or using it as a hashtag
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(getParamAsString(r, "tag"))
+	if tag == "" {
+		app.badRequestResponse(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	fq := store.PaginationFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Tags.GetPosts(r.Context(), tag, viewerID(r), fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordFeedViews(r, posts)
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		List trending tags
+//	@Description	Rank the tags of recent public posts, recent uses weigh more than older ones
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"Time window: 1h, 24h or 7d"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	windowParam := qs.Get("window")
+	if windowParam == "" {
+		windowParam = "24h"
+	}
+
+	window, ok := trendingWindows[windowParam]
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("window must be one of 1h, 24h or 7d"))
+		return
+	}
+
+	limit := 10
+	if l := qs.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 50 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = n
+	}
+
+	tags, err := app.getTrendingTags(r, window)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// maxTrendingTags is how many tags are ranked and cached per window, requests
+// get a prefix of it.
+const maxTrendingTags = 50
+
+// getTrendingTags serves the trending tags from redis when it is enabled.
+// Cache failures fall back to the database.
+func (app *application) getTrendingTags(r *http.Request, window time.Duration) ([]store.TrendingTag, error) {
+	ctx := r.Context()
+	halfLife := window / 4
+
+	if !app.config.redisCfg.enabled {
+		return app.store.Tags.GetTrending(ctx, window, halfLife, maxTrendingTags)
+	}
+
+	tags, err := app.cacheStore.Tags.GetTrending(ctx, window)
+	if err != nil {
+		app.logger.Errorw("failed to read cached trending tags", "window", window.String(), "error", err.Error())
+	}
+
+	if tags != nil {
+		return tags, nil
+	}
+
+	tags, err = app.store.Tags.GetTrending(ctx, window, halfLife, maxTrendingTags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStore.Tags.SetTrending(ctx, window, tags); err != nil {
+		app.logger.Errorw("failed to cache trending tags", "window", window.String(), "error", err.Error())
+	}
+
+	return tags, nil
+}
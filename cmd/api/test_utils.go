@@ -4,12 +4,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
+	"ontopsolutions.net/gasperlf/social/internal/analytics"
 	"ontopsolutions.net/gasperlf/social/internal/auth"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
 	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 	"ontopsolutions.net/gasperlf/social/internal/store/cache"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+	"ontopsolutions.net/gasperlf/social/internal/unsubscribe"
 )
 
 func newTestApplication(t *testing.T, cfg config) *application {
@@ -24,6 +30,8 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	hub := stream.NewHub(stream.Config{ReplaySize: 10, ReplayTTL: time.Minute, BufferSize: 8})
+
 	return &application{
 		config:        cfg,
 		logger:        logger,
@@ -31,6 +39,20 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		cacheStore:    mockCacheUser,
 		authenticator: testAuth,
 		rateLimiter:   rateLimiter,
+		views:         analytics.NewViews(time.Minute),
+		cursors:       cursor.NewSigner("test"),
+		unsubscribes:  unsubscribe.NewSigner("test"),
+		scorer:        ranking.DefaultScorer(),
+		hub:           hub,
+		events:        stream.NewLocal(hub),
+		wsRateLimiter: ratelimiter.NewFixedWindowRateLimiter(
+			cfg.websocket.rateLimiter.RequestsPerTimeFrame,
+			cfg.websocket.rateLimiter.TimeFrame,
+		),
+		mediaRateLimiter: ratelimiter.NewFixedWindowRateLimiter(
+			cfg.media.rateLimiter.RequestsPerTimeFrame,
+			cfg.media.rateLimiter.TimeFrame,
+		),
 	}
 }
 
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// Home timelines are written on post: a new post is pushed to the timeline of
+// every follower of its author. Authors with more followers than the
+// celebrity threshold are not pushed anywhere, their posts are read from the
+// database and merged in when a timeline is served. Without Redis the feed
+// comes from the database alone.
+
+// usesTimeline reports whether a feed query can be served from the timeline.
+// Searches, filters, offsets and pages toward newer posts go to the database.
+func (app *application) usesTimeline(fq store.PaginationFeedQuery) bool {
+	return app.config.redisCfg.enabled &&
+		fq.Search == "" && len(fq.Tags) == 0 && fq.Sort == "desc" && fq.Offset == 0 &&
+		(fq.Cursor == nil || !fq.Cursor.Backward)
+}
+
+// timelineFeed serves a page of the home feed of userID from the timeline. It
+// reports whether more posts follow. Past the oldest post the timeline kept,
+// the page comes from the database.
+func (app *application) timelineFeed(ctx context.Context, userID int64, fq store.PaginationFeedQuery) ([]store.PostWithMetadata, bool, error) {
+	regular, celebrities, err := app.following(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := app.ensureTimeline(ctx, userID, regular); err != nil {
+		return nil, false, err
+	}
+
+	// a few posts more than the page, some may be hidden from the viewer
+	want := fq.Limit + 1 + fq.Limit/2
+
+	entries, err := app.cacheStore.Timelines.Get(ctx, userID, fq.Cursor, want)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// a short read past the first page means the cursor reached the end of
+	// the timeline, it only keeps the newest posts and older ones may be
+	// missing from it
+	if fq.Cursor != nil && len(entries) < want {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	pulled, err := app.store.Posts.GetTimelineEntries(ctx, celebrities, fq.Cursor, want)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries = mergeEntries(entries, pulled)
+	if len(entries) > want {
+		entries = entries[:want]
+	}
+
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+	}
+
+	posts, err := app.store.Posts.GetByIDs(ctx, userID, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// a full read means the timeline goes on, even when hidden posts left
+	// the page short
+	more := len(posts) > fq.Limit || len(entries) == want
+	if len(posts) > fq.Limit {
+		posts = posts[:fq.Limit]
+	}
+
+	return posts, more, nil
+}
+
+// following splits the users followed by userID into the ones whose posts are
+// pushed to timelines and the celebrities whose posts are pulled.
+func (app *application) following(ctx context.Context, userID int64) (regular, celebrities []int64, err error) {
+	followees, err := app.store.Followers.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// users see their own posts in their feed
+	regular = []int64{userID}
+	for _, f := range followees {
+		if app.isCelebrity(f.Followers) {
+			celebrities = append(celebrities, f.UserID)
+		} else {
+			regular = append(regular, f.UserID)
+		}
+	}
+
+	return regular, celebrities, nil
+}
+
+func (app *application) isCelebrity(followers int) bool {
+	return followers > app.config.timeline.celebrityFollowers
+}
+
+// ensureTimeline builds the timeline of userID from the database when it
+// doesn't exist yet, or anymore.
+func (app *application) ensureTimeline(ctx context.Context, userID int64, authors []int64) error {
+	ready, err := app.cacheStore.Timelines.Ready(ctx, userID)
+	if err != nil || ready {
+		return err
+	}
+
+	entries, err := app.store.Posts.GetTimelineEntries(ctx, authors, nil, app.config.timeline.capacity)
+	if err != nil {
+		return err
+	}
+
+	return app.cacheStore.Timelines.Build(ctx, userID, entries, app.config.timeline.capacity)
+}
+
+// fanOutPost pushes a published post to the timelines of the followers of its
+// author, in the background.
+func (app *application) fanOutPost(post *store.Post) {
+	if !app.config.redisCfg.enabled || post.Status != store.PostStatusPublished {
+		return
+	}
+
+	entry := store.TimelineEntry{PostID: post.ID, CreatedAt: post.CreatedAt}
+	authorID := post.UserID
+
+	app.background(func() {
+		ctx := context.Background()
+
+		followers, err := app.store.Followers.CountFollowers(ctx, authorID)
+		if err != nil {
+			app.logger.Errorw("failed to count followers", "user_id", authorID, "error", err.Error())
+			return
+		}
+
+		userIDs := []int64{authorID}
+		if !app.isCelebrity(followers) {
+			ids, err := app.store.Followers.GetFollowerIDs(ctx, authorID)
+			if err != nil {
+				app.logger.Errorw("failed to load followers", "user_id", authorID, "error", err.Error())
+				return
+			}
+			userIDs = append(userIDs, ids...)
+		}
+
+		if err := app.cacheStore.Timelines.Push(ctx, entry, userIDs, app.config.timeline.capacity); err != nil {
+			app.logger.Errorw("failed to fan out post", "post_id", entry.PostID, "error", err.Error())
+		}
+	})
+}
+
+// backfillTimeline adds the recent posts of a newly followed user to the
+// timeline of the follower. Celebrities are pulled on read already.
+func (app *application) backfillTimeline(ctx context.Context, followerID, followedID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	followers, err := app.store.Followers.CountFollowers(ctx, followedID)
+	if err != nil || app.isCelebrity(followers) {
+		return err
+	}
+
+	entries, err := app.store.Posts.GetTimelineEntries(ctx, []int64{followedID}, nil, app.config.timeline.capacity)
+	if err != nil {
+		return err
+	}
+
+	return app.cacheStore.Timelines.Add(ctx, followerID, entries, app.config.timeline.capacity)
+}
+
+// pruneTimeline removes the posts of an unfollowed user from the timeline of
+// the former follower.
+func (app *application) pruneTimeline(ctx context.Context, followerID, unfollowedID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	entries, err := app.store.Posts.GetTimelineEntries(ctx, []int64{unfollowedID}, nil, app.config.timeline.capacity)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+	}
+
+	return app.cacheStore.Timelines.Remove(ctx, followerID, ids)
+}
+
+// mergeEntries merges timeline entries newest first, dropping duplicates.
+func mergeEntries(a, b []store.TimelineEntry) []store.TimelineEntry {
+	seen := make(map[int64]bool, len(a)+len(b))
+	merged := make([]store.TimelineEntry, 0, len(a)+len(b))
+	for _, e := range append(a, b...) {
+		if !seen[e.PostID] {
+			seen[e.PostID] = true
+			merged = append(merged, e)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].CreatedAt.After(merged[j].CreatedAt)
+		}
+		return merged[i].PostID > merged[j].PostID
+	})
+
+	return merged
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// GetTrash godoc
+//
+//	@Summary		List deleted posts
+//	@Description	List the posts of the authenticated user that are in the trash and can still be restored
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]store.Post
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetTrash(r.Context(), user.ID, app.trashCutoff())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restore a deleted post
+//	@Description	Take a post the authenticated user deleted out of the trash, posts removed by an admin stay deleted
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash/{postID}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := getParamAsInt(r, "postID")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid post id"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	if err := app.store.Posts.Restore(r.Context(), postID, user.ID, app.trashCutoff()); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPosts(postID)
+	app.notContent(w)
+}
+
+func (app *application) purgeDeletedPosts(ctx context.Context) error {
+	for {
+		ids, err := app.store.Posts.PurgeDeleted(ctx, app.trashCutoff(), app.config.jobs.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			app.logger.Infow("deleted posts purged", "count", len(ids))
+		}
+
+		if len(ids) == 0 || len(ids) < app.config.jobs.batchSize {
+			return nil
+		}
+	}
+}
+
+// trashCutoff is the oldest deletion time that can still be restored.
+func (app *application) trashCutoff() time.Time {
+	return time.Now().Add(-app.config.posts.trashRetention)
+}
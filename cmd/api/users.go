@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"ontopsolutions.net/gasperlf/social/internal/store"
@@ -61,6 +62,97 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetUserPosts godoc
+//
+//	@Summary		List the posts of a user
+//	@Description	List the published posts of a user, newest first. The first page starts with the pinned posts, pass the ID of the last unpinned post as before to get the next page.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			before	query		int		false	"Return posts older than this post ID"
+//	@Param			cursor	query		string	false	"Cursor from next_cursor or prev_cursor, instead of before"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getParamAsInt(r, "userID")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid user id"))
+		return
+	}
+
+	uq := store.UserPostsQuery{
+		Limit: 20,
+	}
+
+	uq, err = uq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	uq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if uq.Cursor != nil && uq.Before > 0 {
+		app.badRequestResponse(w, r, errCursorWithOffset)
+		return
+	}
+
+	if err := Validate.Struct(uq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := app.getUser(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, more, err := app.store.Posts.GetUserPosts(ctx, userID, viewerID(r), uq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordFeedViews(r, posts)
+
+	// the pinned posts on top of the first page are not part of the list
+	// cursors move through
+	page := posts
+	for len(page) > 0 && page[0].PinnedAt != nil {
+		page = page[1:]
+	}
+
+	offset := 0
+	if uq.Before > 0 {
+		offset = 1
+	}
+	next, prev := app.pageCursors(uq.Cursor, offset, more, len(page), func(i int) (time.Time, int64) {
+		return page[i].CreatedAt, page[i].ID
+	})
+
+	if err := app.jsonPageResponse(w, http.StatusOK, posts, next, prev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // FollowUser godoc
 //
 //	@Summary		Follow a user
@@ -99,6 +191,16 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	if err := app.backfillTimeline(ctx, followerUser.ID, followedID); err != nil {
+		app.logger.Errorw("failed to backfill timeline", "user_id", followerUser.ID, "followed_id", followedID, "error", err.Error())
+	}
+
+	app.notify(store.NotificationEvent{
+		UserID:  followedID,
+		ActorID: followerUser.ID,
+		Type:    store.NotificationFollow,
+	})
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -135,6 +237,10 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := app.pruneTimeline(ctx, unfollowedUser.ID, unfollowedID); err != nil {
+		app.logger.Errorw("failed to prune timeline", "user_id", unfollowedUser.ID, "unfollowed_id", unfollowedID, "error", err.Error())
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -156,7 +262,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	token := getParamAsString(r, "token")
 
 	ctx := r.Context()
-	err := app.store.Users.Activate(ctx, token)
+	user, err := app.store.Users.Activate(ctx, token)
 
 	if err != nil {
 		switch err {
@@ -167,6 +273,8 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+
+	app.indexUsers(user.ID)
 	if err := app.jsonResponse(w, http.StatusNoContent, ""); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// GetPostStats godoc
+//
+//	@Summary		Get the stats of a post
+//	@Description	Views, unique viewers, comments and poll votes of one of your posts, by UTC day
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			days	query		int	false	"Number of days, up to 90"
+//	@Success		200		{object}	store.PostStats
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/stats [get]
+func (app *application) getPostStatsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := getPostFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("only the author can see the stats of a post"))
+		return
+	}
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > store.MaxStatsDays {
+			app.badRequestResponse(w, r, errors.New("days must be between 1 and 90"))
+			return
+		}
+		days = n
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	if since.Before(post.CreatedAt) {
+		since = post.CreatedAt
+	}
+
+	stats, err := app.store.PostViews.GetStats(r.Context(), post.ID, since)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, stats); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// recordViews counts the posts served to the viewer of the request. Authors
+// viewing their own posts don't count.
+func (app *application) recordViews(r *http.Request, posts ...*store.Post) {
+	viewer := viewerID(r)
+
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		if post.UserID != viewer {
+			ids = append(ids, post.ID)
+		}
+	}
+
+	app.views.Record(viewer, ids...)
+}
+
+func (app *application) recordFeedViews(r *http.Request, feed []store.PostWithMetadata) {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+
+	app.recordViews(r, posts...)
+}
+
+// flushPostViews writes the buffered views to the database. A batch that
+// fails is kept for the next flush.
+func (app *application) flushPostViews(ctx context.Context) error {
+	batch := app.views.Drain()
+	if batch.Empty() {
+		return nil
+	}
+
+	if err := app.store.PostViews.Add(ctx, batch); err != nil {
+		app.views.Restore(batch)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+)
+
+// messages sent by the client
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsTyping      = "typing"
+	wsPing        = "ping"
+)
+
+// messages sent by the server
+const (
+	wsAck   = "ack"
+	wsError = "error"
+	wsPong  = "pong"
+	wsEvent = "event"
+)
+
+// channels a client subscribes to, dm and post channels are followed by the
+// ID of the other user or the post
+const (
+	wsChannelTimeline = "timeline"
+	wsChannelPost     = "post:"
+	wsChannelDM       = "dm:"
+)
+
+const streamEventTyping = "typing"
+
+var (
+	errWSUnknownChannel    = errors.New("unknown channel")
+	errWSUnknownType       = errors.New("unknown message type")
+	errWSTooManyChannels   = errors.New("too many subscriptions")
+	errWSNotSubscribed     = errors.New("not subscribed to the channel")
+	errWSRateLimitExceeded = errors.New("rate limit exceeded")
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// clients authenticate with a bearer token, not a cookie, a page from
+	// another origin can't act on behalf of a user
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WSMessage is a message sent by the client. ID is echoed back in the reply
+// so the client can match them.
+type WSMessage struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// WSReply is a message sent by the server, a reply to a client message or an
+// event on one of its channels.
+type WSReply struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Channel string          `json:"channel,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type streamTyping struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// WebSocket godoc
+//
+//	@Summary		Open a WebSocket
+//	@Description	Real-time API over a WebSocket. Send {"type": "subscribe", "channel": "timeline"} to get the new posts of followed users, "post:{postID}" for the new comments on a post and "dm:{userID}" for the typing indicators of a conversation with a user followed back. Every message is acknowledged, a client that can't keep up is disconnected. Browsers pass the token in the access_token param.
+//	@Tags			stream
+//	@Param			access_token	query		string	false	"Bearer token, when the Authorization header can't be set"
+//	@Success		101				{string}	string
+//	@Failure		401				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/ws [get]
+func (app *application) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("access_token")
+	if header := r.Header.Get("Authorization"); header != "" {
+		parts := strings.Split(header, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			app.unauthorizeErrorResponse(w, r, errors.New("unauthorization header is malformed"))
+			return
+		}
+		token = parts[1]
+	}
+
+	if token == "" {
+		app.unauthorizeErrorResponse(w, r, errors.New("unauthorization header is missing"))
+		return
+	}
+
+	user, err := app.authenticateToken(r.Context(), token)
+	if err != nil {
+		app.unauthorizeErrorResponse(w, r, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied
+		return
+	}
+
+	c := &wsConn{
+		app:  app,
+		conn: conn,
+		user: user,
+		id:   uuid.NewString(),
+		send: make(chan WSReply, app.config.websocket.sendBuffer),
+		subs: make(map[string]*wsSubscription),
+		done: make(chan struct{}),
+	}
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+// wsConn is a client connection. Only readLoop touches the subscriptions and
+// only writeLoop writes messages.
+type wsConn struct {
+	app  *application
+	conn *websocket.Conn
+	user *store.User
+	// id keys the rate limit of the connection
+	id   string
+	send chan WSReply
+	subs map[string]*wsSubscription
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type wsSubscription struct {
+	sub *stream.Subscription
+	// stopped is set when the client unsubscribes, the hub closing the
+	// subscription otherwise means the connection fell behind
+	stopped atomic.Bool
+}
+
+func (c *wsConn) readLoop() {
+	defer func() {
+		for _, s := range c.subs {
+			s.stopped.Store(true)
+			c.app.hub.Unsubscribe(s.sub)
+		}
+		c.close(websocket.CloseNormalClosure, "")
+	}()
+
+	cfg := c.app.config.websocket
+	c.conn.SetReadLimit(cfg.maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(cfg.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(cfg.pongWait))
+	})
+
+	for {
+		var msg WSMessage
+		err := c.conn.ReadJSON(&msg)
+
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		malformed := errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+		if err != nil && !malformed {
+			return
+		}
+
+		// malformed messages count too, or they could be sent without limit
+		if cfg.rateLimiter.Enabled {
+			if allow, _ := c.app.wsRateLimiter.Allow(c.id); !allow {
+				c.reply(WSReply{Type: wsError, ID: msg.ID, Error: errWSRateLimitExceeded.Error()})
+				continue
+			}
+		}
+
+		if malformed {
+			c.reply(WSReply{Type: wsError, Error: "invalid message"})
+			continue
+		}
+
+		if err := c.handle(msg); err != nil {
+			c.reply(WSReply{Type: wsError, ID: msg.ID, Channel: msg.Channel, Error: err.Error()})
+			continue
+		}
+
+		if msg.Type == wsPing {
+			c.reply(WSReply{Type: wsPong, ID: msg.ID})
+			continue
+		}
+		c.reply(WSReply{Type: wsAck, ID: msg.ID, Channel: msg.Channel})
+	}
+}
+
+func (c *wsConn) handle(msg WSMessage) error {
+	switch msg.Type {
+	case wsSubscribe:
+		return c.subscribe(msg.Channel)
+	case wsUnsubscribe:
+		s, ok := c.subs[msg.Channel]
+		if !ok {
+			return errWSNotSubscribed
+		}
+		s.stopped.Store(true)
+		c.app.hub.Unsubscribe(s.sub)
+		delete(c.subs, msg.Channel)
+		return nil
+	case wsTyping:
+		otherID, ok := strings.CutPrefix(msg.Channel, wsChannelDM)
+		if !ok {
+			return errWSUnknownChannel
+		}
+		if _, ok := c.subs[msg.Channel]; !ok {
+			return errWSNotSubscribed
+		}
+		id, _ := strconv.ParseInt(otherID, 10, 64)
+		c.app.publishEvent(stream.DMTopic(c.user.ID, id), streamEventTyping, streamTyping{
+			UserID:   c.user.ID,
+			Username: c.user.Username,
+		})
+		return nil
+	case wsPing:
+		return nil
+	default:
+		return errWSUnknownType
+	}
+}
+
+func (c *wsConn) subscribe(channel string) error {
+	if _, ok := c.subs[channel]; ok {
+		return nil
+	}
+
+	if len(c.subs) >= c.app.config.websocket.maxSubscriptions {
+		return errWSTooManyChannels
+	}
+
+	topics, err := c.channelTopics(channel)
+	if err != nil {
+		return err
+	}
+
+	s := &wsSubscription{}
+	s.sub, _, _ = c.app.hub.Subscribe(topics, 0)
+	c.subs[channel] = s
+
+	go c.forward(channel, s)
+	return nil
+}
+
+// channelTopics checks that the user can subscribe to channel and lists its
+// topics. The timeline follows the users followed when subscribing.
+func (c *wsConn) channelTopics(channel string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), store.QueryTimeoutDuration)
+	defer cancel()
+
+	switch {
+	case channel == wsChannelTimeline:
+		following, err := c.app.store.Followers.GetFollowing(ctx, c.user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		topics := []string{stream.AuthorTopic(c.user.ID)}
+		for _, f := range following {
+			topics = append(topics, stream.AuthorTopic(f.UserID))
+		}
+		return topics, nil
+
+	case strings.HasPrefix(channel, wsChannelPost):
+		id, err := strconv.ParseInt(strings.TrimPrefix(channel, wsChannelPost), 10, 64)
+		if err != nil {
+			return nil, errWSUnknownChannel
+		}
+
+		post, err := c.app.store.Posts.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, store.ErrorNotFound) {
+				return nil, errWSUnknownChannel
+			}
+			return nil, err
+		}
+
+		visible, err := c.app.store.Posts.CanView(ctx, c.user.ID, post)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, errWSUnknownChannel
+		}
+		return []string{stream.PostTopic(id)}, nil
+
+	case strings.HasPrefix(channel, wsChannelDM):
+		id, err := strconv.ParseInt(strings.TrimPrefix(channel, wsChannelDM), 10, 64)
+		if err != nil || id == c.user.ID {
+			return nil, errWSUnknownChannel
+		}
+
+		// only users who follow each other get to see each other typing
+		mutual, err := c.app.store.Followers.IsMutual(ctx, c.user.ID, id)
+		if err != nil {
+			return nil, err
+		}
+		if !mutual {
+			return nil, errWSUnknownChannel
+		}
+		return []string{stream.DMTopic(c.user.ID, id)}, nil
+
+	default:
+		return nil, errWSUnknownChannel
+	}
+}
+
+// forward queues the events of a subscription for the client. The hub drops
+// subscriptions that fall behind, the connection goes with them.
+func (c *wsConn) forward(channel string, s *wsSubscription) {
+	for e := range s.sub.Events {
+		if e.Type == streamEventTyping && c.isOwnTyping(e) {
+			continue
+		}
+
+		if !c.reply(WSReply{Type: wsEvent, Channel: channel, Event: e.Type, Data: e.Data}) {
+			return
+		}
+	}
+
+	if !s.stopped.Load() {
+		c.close(websocket.CloseTryAgainLater, "too slow, reconnect")
+	}
+}
+
+func (c *wsConn) isOwnTyping(e stream.Event) bool {
+	var t streamTyping
+	return json.Unmarshal(e.Data, &t) == nil && t.UserID == c.user.ID
+}
+
+// reply queues a message without blocking, a client that doesn't read its
+// messages is disconnected.
+func (c *wsConn) reply(msg WSReply) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.close(websocket.CloseTryAgainLater, "too slow, reconnect")
+		return false
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	cfg := c.app.config.websocket
+	ping := time.NewTicker(cfg.pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(cfg.writeWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cfg.writeWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// close tells the client why, when it can, and closes the connection, which
+// ends both loops.
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if code != websocket.CloseAbnormalClosure {
+			msg := websocket.FormatCloseMessage(code, reason)
+			_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		}
+		_ = c.conn.Close()
+	})
+}
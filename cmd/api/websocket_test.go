@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"ontopsolutions.net/gasperlf/social/internal/ratelimiter"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+	"ontopsolutions.net/gasperlf/social/internal/stream"
+)
+
+func newWSTestServer(t *testing.T, messages int) (*application, *httptest.Server) {
+	t.Helper()
+
+	cfg := config{
+		rateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 20,
+			TimeFrame:            time.Second * 5,
+			Enabled:              true,
+		},
+		websocket: websocketConfig{
+			maxMessageSize:   1024,
+			pingInterval:     time.Second,
+			pongWait:         time.Second * 5,
+			writeWait:        time.Second,
+			sendBuffer:       4,
+			maxSubscriptions: 2,
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: messages,
+				TimeFrame:            time.Minute,
+				Enabled:              true,
+			},
+		},
+		addr: ":8080",
+	}
+
+	app := newTestApplication(t, cfg)
+	srv := httptest.NewServer(mount(app))
+	t.Cleanup(srv.Close)
+
+	return app, srv
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, token string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// sendWS sends msg and reads the reply to it.
+func sendWS(t *testing.T, conn *websocket.Conn, msg WSMessage) WSReply {
+	t.Helper()
+
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return readWS(t, conn)
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) WSReply {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+
+	var reply WSReply
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+
+	return reply
+}
+
+func TestWebSocket(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should not allow unauthorized connections", func(t *testing.T) {
+		_, srv := newWSTestServer(t, 20)
+
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws"
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			t.Fatal("got a connection")
+		}
+		checkResponseCode(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should accept the token as a param", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws?access_token=" + token
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+
+	t.Run("should answer pings", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		reply := sendWS(t, conn, WSMessage{ID: "1", Type: wsPing})
+		if reply.Type != wsPong || reply.ID != "1" {
+			t.Errorf("got %+v, want a pong", reply)
+		}
+	})
+
+	t.Run("should deliver the events of subscribed channels", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		reply := sendWS(t, conn, WSMessage{ID: "1", Type: wsSubscribe, Channel: "dm:2"})
+		if reply.Type != wsAck || reply.ID != "1" {
+			t.Fatalf("got %+v, want an ack", reply)
+		}
+
+		typing := streamTyping{UserID: 2, Username: "other"}
+		if err := app.events.Publish(ctx, stream.DMTopic(2, 1), streamEventTyping, typing); err != nil {
+			t.Fatal(err)
+		}
+
+		reply = readWS(t, conn)
+		if reply.Type != wsEvent || reply.Channel != "dm:2" || reply.Event != streamEventTyping {
+			t.Errorf("got %+v, want the typing event", reply)
+		}
+	})
+
+	t.Run("should not echo the typing of the user", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:2"})
+		reply := sendWS(t, conn, WSMessage{ID: "2", Type: wsTyping, Channel: "dm:2"})
+		if reply.Type != wsAck {
+			t.Fatalf("got %+v, want an ack", reply)
+		}
+
+		reply = sendWS(t, conn, WSMessage{ID: "3", Type: wsPing})
+		if reply.Type != wsPong {
+			t.Errorf("got %+v, want only the pong", reply)
+		}
+	})
+
+	t.Run("should stop delivering after unsubscribing", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:2"})
+		reply := sendWS(t, conn, WSMessage{ID: "2", Type: wsUnsubscribe, Channel: "dm:2"})
+		if reply.Type != wsAck {
+			t.Fatalf("got %+v, want an ack", reply)
+		}
+
+		_ = app.events.Publish(ctx, stream.DMTopic(2, 1), streamEventTyping, streamTyping{UserID: 2})
+
+		reply = sendWS(t, conn, WSMessage{ID: "3", Type: wsPing})
+		if reply.Type != wsPong {
+			t.Errorf("got %+v, want only the pong", reply)
+		}
+	})
+
+	t.Run("should reject unknown channels and messages", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		for _, msg := range []WSMessage{
+			{ID: "1", Type: wsSubscribe, Channel: "everything"},
+			{ID: "2", Type: wsSubscribe, Channel: "dm:1"},
+			{ID: "3", Type: wsTyping, Channel: "dm:2"},
+			{ID: "4", Type: "shout"},
+		} {
+			reply := sendWS(t, conn, msg)
+			if reply.Type != wsError || reply.ID != msg.ID {
+				t.Errorf("got %+v for %+v, want an error", reply, msg)
+			}
+		}
+	})
+
+	t.Run("should only open conversations between mutual followers", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		app.store.Followers = &store.MockFollowerStore{Mutual: false}
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		reply := sendWS(t, conn, WSMessage{ID: "1", Type: wsSubscribe, Channel: "dm:2"})
+		if reply.Type != wsError || reply.Error != errWSUnknownChannel.Error() {
+			t.Errorf("got %+v, want an unknown channel", reply)
+		}
+	})
+
+	t.Run("should limit the subscriptions", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:2"})
+		sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:3"})
+		reply := sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:4"})
+		if reply.Error != errWSTooManyChannels.Error() {
+			t.Errorf("got %+v, want too many subscriptions", reply)
+		}
+	})
+
+	t.Run("should rate limit the messages of a connection", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 3)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		for range 3 {
+			if reply := sendWS(t, conn, WSMessage{Type: wsPing}); reply.Type != wsPong {
+				t.Fatalf("got %+v, want a pong", reply)
+			}
+		}
+
+		reply := sendWS(t, conn, WSMessage{Type: wsPing})
+		if reply.Error != errWSRateLimitExceeded.Error() {
+			t.Errorf("got %+v, want the rate limit", reply)
+		}
+
+		// another connection has its own limit
+		other := dialWS(t, srv, token)
+		if reply := sendWS(t, other, WSMessage{Type: wsPing}); reply.Type != wsPong {
+			t.Errorf("got %+v, want a pong", reply)
+		}
+	})
+
+	t.Run("should rate limit malformed messages", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 2)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		for range 2 {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+				t.Fatal(err)
+			}
+			if reply := readWS(t, conn); reply.Error != "invalid message" {
+				t.Fatalf("got %+v, want an invalid message", reply)
+			}
+		}
+
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+		if reply := readWS(t, conn); reply.Error != errWSRateLimitExceeded.Error() {
+			t.Errorf("got %+v, want the rate limit", reply)
+		}
+	})
+
+	t.Run("should drop clients that don't keep up", func(t *testing.T) {
+		app, srv := newWSTestServer(t, 20)
+		token, _ := app.authenticator.GenerateToken(nil)
+		conn := dialWS(t, srv, token)
+
+		sendWS(t, conn, WSMessage{Type: wsSubscribe, Channel: "dm:2"})
+
+		// fill the socket buffers while the client doesn't read
+		typing := streamTyping{UserID: 2, Username: strings.Repeat("x", 64<<10)}
+		for range 500 {
+			_ = app.events.Publish(ctx, stream.DMTopic(2, 1), streamEventTyping, typing)
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		received := 0
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+			received++
+		}
+
+		if received >= 500 {
+			t.Errorf("got all %d events, want the client dropped", received)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_comments_post_thread;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS deleted_by;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS edited_at;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS depth;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,18 @@
+ALTER TABLE comments
+    ADD COLUMN parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE;
+
+ALTER TABLE comments
+    ADD COLUMN depth INT NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+    ADD COLUMN edited_at TIMESTAMP(0) WITH TIME ZONE;
+COMMENT ON COLUMN comments.edited_at IS 'Timestamp of the last edit made by the comment author.';
+
+ALTER TABLE comments
+    ADD COLUMN deleted_at TIMESTAMP(0) WITH TIME ZONE;
+
+ALTER TABLE comments
+    ADD COLUMN deleted_by BIGINT REFERENCES users(id);
+COMMENT ON COLUMN comments.deleted_by IS 'User that deleted the comment, either its author or a moderator.';
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_thread ON comments (post_id, parent_id, created_at, id);
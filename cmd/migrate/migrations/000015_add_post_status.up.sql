@@ -0,0 +1,11 @@
+ALTER TABLE posts
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+COMMENT ON COLUMN posts.status IS 'Publication status of the post: draft, scheduled or published.';
+
+ALTER TABLE posts
+    ADD COLUMN publish_at TIMESTAMP(0) WITH TIME ZONE;
+COMMENT ON COLUMN posts.publish_at IS 'When a scheduled post has to be published.';
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
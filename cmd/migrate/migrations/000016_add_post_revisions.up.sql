@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    tags VARCHAR(100) [],
+    editor_id BIGINT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (post_id, version)
+);
+
+COMMENT ON TABLE post_revisions IS 'Snapshot of every version of a post, the latest one matches the post itself.';
+
+-- existing posts start their history with their current content
+INSERT INTO post_revisions (post_id, version, title, content, tags, editor_id, created_at)
+SELECT id, COALESCE(version, 0), title, content, tags, user_id, updated_at
+FROM posts;
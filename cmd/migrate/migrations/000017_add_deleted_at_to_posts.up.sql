@@ -0,0 +1,5 @@
+ALTER TABLE posts
+    ADD COLUMN deleted_at TIMESTAMP(0) WITH TIME ZONE;
+COMMENT ON COLUMN posts.deleted_at IS 'When the post was moved to the trash, it is purged after the retention period.';
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
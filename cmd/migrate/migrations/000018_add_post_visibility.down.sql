@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS post_mentions;
+
+DROP INDEX IF EXISTS idx_followers_follower_id;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,17 @@
+ALTER TABLE posts
+    ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+    CONSTRAINT posts_visibility_check CHECK (visibility IN ('public', 'followers', 'mentioned'));
+COMMENT ON COLUMN posts.visibility IS 'Who can see the post besides its author: everyone, followers of the author or mentioned users.';
+
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
+
+-- the audience of mentioned-only posts. Mentions removed by an edit are
+-- deactivated, which takes the access away.
+CREATE TABLE IF NOT EXISTS post_mentions (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_mentions_user_id ON post_mentions (user_id);
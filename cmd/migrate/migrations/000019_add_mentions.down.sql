@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS comment_mentions;
+
+ALTER TABLE post_mentions
+    DROP COLUMN IF EXISTS notified_at;
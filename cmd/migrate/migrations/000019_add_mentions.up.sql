@@ -0,0 +1,14 @@
+-- mentions removed by an edit are deactivated instead of deleted, so users
+-- mentioned again later aren't notified twice
+ALTER TABLE post_mentions
+    ADD COLUMN IF NOT EXISTS notified_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    notified_at TIMESTAMP(0) WITH TIME ZONE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user_id ON comment_mentions (user_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_posts_created_at;
+
+-- posts edited since keep the tags of the edit
+UPDATE posts p
+SET tags = u.tags
+FROM post_tags_unnormalized u
+WHERE u.post_id = p.id AND u.version IS NOT DISTINCT FROM p.version;
+
+DROP TABLE IF EXISTS post_tags_unnormalized;
@@ -0,0 +1,28 @@
+-- the tags as they were, for the down migration to restore
+CREATE TABLE IF NOT EXISTS post_tags_unnormalized (
+    post_id BIGINT PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    tags varchar(100) [],
+    version INT
+);
+
+INSERT INTO post_tags_unnormalized (post_id, tags, version)
+SELECT id, tags, version FROM posts;
+
+-- tags are stored normalized the way entities.Tags does it, by case and
+-- unicode form and with the hashtags of the content merged in. A hashtag
+-- can't follow a letter, digit or underscore and needs at least one letter.
+UPDATE posts
+SET tags = ARRAY(
+    SELECT DISTINCT tag FROM (
+        SELECT lower(normalize(regexp_replace(btrim(raw), '^#', ''), NFKC)) AS tag
+        FROM (
+            SELECT unnest(COALESCE(tags, '{}')) AS raw
+            UNION ALL
+            SELECT m[1] FROM regexp_matches(content, '(?:^|[^[:alnum:]_])#([[:alnum:]_]+)', 'g') AS m
+            WHERE m[1] ~ '[[:alpha:]]'
+        ) found
+    ) normalized
+    WHERE tag <> '' AND char_length(tag) <= 100
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS polls (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL UNIQUE REFERENCES posts(id) ON DELETE CASCADE,
+    multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+    hide_results BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN polls.hide_results IS 'Hide the results from users until they vote or the poll closes.';
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    text VARCHAR(100) NOT NULL,
+    UNIQUE (poll_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    option_id BIGINT NOT NULL REFERENCES poll_options(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (poll_id, option_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option_id ON poll_votes (option_id);
+CREATE INDEX IF NOT EXISTS idx_poll_votes_user_id ON poll_votes (poll_id, user_id);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL,
+    position INT NOT NULL DEFAULT 0,
+    alt_text VARCHAR(1500) NOT NULL DEFAULT '',
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    blurhash VARCHAR(100) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN attachments.post_id IS 'NULL until the upload is referenced by a post, orphaned uploads are garbage collected.';
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_attachments_orphaned ON attachments (created_at) WHERE post_id IS NULL;
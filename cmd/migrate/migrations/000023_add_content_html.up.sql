@@ -0,0 +1,6 @@
+-- rendered when the content is written, rows from before are rendered on read
+ALTER TABLE posts
+    ADD COLUMN content_html TEXT;
+
+ALTER TABLE comments
+    ADD COLUMN content_html TEXT;
@@ -0,0 +1,17 @@
+-- one row per URL, shared by every post linking to it
+CREATE TABLE IF NOT EXISTS link_previews (
+    url TEXT PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'fetching', 'ready', 'failed')),
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    claimed_at TIMESTAMP(0) WITH TIME ZONE,
+    fetched_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_previews_pending ON link_previews (created_at) WHERE status IN ('pending', 'fetching');
+
+ALTER TABLE posts
+    ADD COLUMN preview_url TEXT;
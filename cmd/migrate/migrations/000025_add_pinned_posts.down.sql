@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_user_created_at;
+DROP INDEX IF EXISTS idx_posts_pinned;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS pinned_at;
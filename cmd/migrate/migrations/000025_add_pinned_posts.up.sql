@@ -0,0 +1,5 @@
+ALTER TABLE posts
+    ADD COLUMN pinned_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_pinned ON posts (user_id, pinned_at) WHERE pinned_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_user_created_at ON posts (user_id, created_at DESC, id DESC);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS moderation_log;
+DROP TABLE IF EXISTS user_preferences;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS content_warning_forced;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS sensitive;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS content_warning;
@@ -0,0 +1,28 @@
+ALTER TABLE posts
+    ADD COLUMN content_warning VARCHAR(200) NOT NULL DEFAULT '';
+
+ALTER TABLE posts
+    ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE posts
+    ADD COLUMN content_warning_forced BOOLEAN NOT NULL DEFAULT FALSE;
+COMMENT ON COLUMN posts.content_warning_forced IS 'The content warning was set by a moderator, the author can not remove it.';
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    flagged_content VARCHAR(10) NOT NULL DEFAULT 'warn' CHECK (flagged_content IN ('warn', 'expand', 'hide')),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+COMMENT ON COLUMN user_preferences.flagged_content IS 'How posts with a content warning or marked sensitive are shown: collapsed, expanded or left out of feeds.';
+
+CREATE TABLE IF NOT EXISTS moderation_log (
+    id BIGSERIAL PRIMARY KEY,
+    moderator_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL,
+    details TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_log_post_id ON moderation_log (post_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_expires_at;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS expires_at;
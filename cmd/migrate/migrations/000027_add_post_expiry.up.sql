@@ -0,0 +1,4 @@
+ALTER TABLE posts
+    ADD COLUMN expires_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_expires_at ON posts (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS post_views (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS post_viewers (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    viewer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, day, viewer_id)
+);
+
+COMMENT ON TABLE post_viewers IS 'Signed in users who viewed a post, per UTC day, to count unique viewers.';
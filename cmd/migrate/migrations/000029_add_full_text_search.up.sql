@@ -0,0 +1,31 @@
+ALTER TABLE posts
+    ADD COLUMN language regconfig NOT NULL DEFAULT 'english';
+COMMENT ON COLUMN posts.language IS 'Text search configuration the post is stemmed with.';
+
+ALTER TABLE comments
+    ADD COLUMN language regconfig NOT NULL DEFAULT 'english';
+COMMENT ON COLUMN comments.language IS 'Text search configuration of the post the comment was left on.';
+
+-- words are indexed stemmed in the language of the text and as they are, so
+-- searching in another language still finds exact words
+ALTER TABLE posts
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector(language, coalesce(title, '')), 'A') ||
+        setweight(to_tsvector(language, coalesce(content, '')), 'B') ||
+        setweight(to_tsvector('simple'::regconfig, coalesce(title, '') || ' ' || coalesce(content, '')), 'D')
+    ) STORED;
+
+ALTER TABLE comments
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector(language, coalesce(content, '')), 'B') ||
+        setweight(to_tsvector('simple'::regconfig, coalesce(content, '')), 'D')
+    ) STORED;
+
+ALTER TABLE users
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple'::regconfig, username)
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING gin (search_vector);
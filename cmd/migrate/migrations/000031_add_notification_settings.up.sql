@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    deliveries JSONB NOT NULL DEFAULT '{}',
+    quiet_start TIME,
+    quiet_end TIME,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    digest VARCHAR(10) NOT NULL DEFAULT 'off',
+    last_digest_at TIMESTAMP(0) WITH TIME ZONE,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN notification_settings.deliveries IS 'How each notification type is delivered: in_app, email, all or none. Missing types get the defaults.';
+
+CREATE INDEX IF NOT EXISTS idx_notification_settings_digest ON notification_settings (user_id) WHERE digest <> 'off';
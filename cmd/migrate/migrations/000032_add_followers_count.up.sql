@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count INT NOT NULL DEFAULT 0;
+COMMENT ON COLUMN users.followers_count IS 'Number of followers, kept up to date on follow, unfollow and user deletion.';
+
+UPDATE users u SET followers_count = (SELECT count(*) FROM followers f WHERE f.user_id = u.id);
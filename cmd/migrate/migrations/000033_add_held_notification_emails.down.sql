@@ -0,0 +1 @@
+DROP TABLE IF EXISTS held_notification_emails;
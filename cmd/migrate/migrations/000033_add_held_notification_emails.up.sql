@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS held_notification_emails (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    send_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE held_notification_emails IS 'Notification emails held back by quiet hours, sent once they are over.';
+
+CREATE INDEX IF NOT EXISTS idx_held_notification_emails_send_at ON held_notification_emails (send_at, id);
@@ -0,0 +1,7 @@
+ALTER TABLE posts
+    ADD COLUMN deleted_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+COMMENT ON COLUMN posts.deleted_by IS 'Who moved the post to the trash, authors only restore the posts they deleted themselves.';
+
+-- who deleted the posts already in the trash wasn't recorded, they stay
+-- restorable by their authors
+UPDATE posts SET deleted_by = user_id WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+)
+
+// Day is the UTC date views are bucketed by, formatted as 2006-01-02.
+type Day = string
+
+// DailyViews is the number of views a post got on a day.
+type DailyViews struct {
+	PostID int64
+	Day    Day
+	Views  int64
+}
+
+// DailyViewer is a signed in user who viewed a post on a day.
+type DailyViewer struct {
+	PostID   int64
+	Day      Day
+	ViewerID int64
+}
+
+// Batch holds the views buffered since the previous flush.
+type Batch struct {
+	Views   []DailyViews
+	Viewers []DailyViewer
+}
+
+func (b Batch) Empty() bool {
+	return len(b.Views) == 0 && len(b.Viewers) == 0
+}
+
+type seenKey struct {
+	postID   int64
+	viewerID int64
+}
+
+type dayKey struct {
+	postID int64
+	day    Day
+}
+
+// Views buffers post impressions in memory so that serving a post doesn't
+// cost a write. A signed in viewer seeing the same post again within the
+// window counts once. Deduplication is per instance, a viewer served by two
+// instances within the window may count twice.
+type Views struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	seen    map[seenKey]time.Time
+	views   map[dayKey]int64
+	viewers map[DailyViewer]struct{}
+}
+
+func NewViews(window time.Duration) *Views {
+	return &Views{
+		window:  window,
+		now:     time.Now,
+		seen:    make(map[seenKey]time.Time),
+		views:   make(map[dayKey]int64),
+		viewers: make(map[DailyViewer]struct{}),
+	}
+}
+
+// Record counts a view of each post by viewerID, 0 for anonymous viewers.
+func (v *Views) Record(viewerID int64, postIDs ...int64) {
+	now := v.now()
+	day := now.UTC().Format(time.DateOnly)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, postID := range postIDs {
+		if viewerID != 0 {
+			key := seenKey{postID: postID, viewerID: viewerID}
+			if last, ok := v.seen[key]; ok && now.Sub(last) < v.window {
+				continue
+			}
+			v.seen[key] = now
+			v.viewers[DailyViewer{PostID: postID, Day: day, ViewerID: viewerID}] = struct{}{}
+		}
+
+		v.views[dayKey{postID: postID, day: day}]++
+	}
+}
+
+// Drain returns the buffered views and starts a new batch. Viewers last seen
+// before the window are forgotten.
+func (v *Views) Drain() Batch {
+	now := v.now()
+
+	v.mu.Lock()
+	views, viewers := v.views, v.viewers
+	v.views = make(map[dayKey]int64)
+	v.viewers = make(map[DailyViewer]struct{})
+
+	for key, last := range v.seen {
+		if now.Sub(last) >= v.window {
+			delete(v.seen, key)
+		}
+	}
+	v.mu.Unlock()
+
+	batch := Batch{
+		Views:   make([]DailyViews, 0, len(views)),
+		Viewers: make([]DailyViewer, 0, len(viewers)),
+	}
+	for key, count := range views {
+		batch.Views = append(batch.Views, DailyViews{PostID: key.postID, Day: key.day, Views: count})
+	}
+	for viewer := range viewers {
+		batch.Viewers = append(batch.Viewers, viewer)
+	}
+
+	return batch
+}
+
+// Restore puts back a batch that could not be flushed, to be retried with the
+// next one.
+func (v *Views) Restore(batch Batch) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, dv := range batch.Views {
+		v.views[dayKey{postID: dv.PostID, day: dv.Day}] += dv.Views
+	}
+	for _, viewer := range batch.Viewers {
+		v.viewers[viewer] = struct{}{}
+	}
+}
@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestViews(window time.Duration, now *time.Time) *Views {
+	v := NewViews(window)
+	v.now = func() time.Time { return *now }
+	return v
+}
+
+func viewsOf(batch Batch, postID int64, day Day) int64 {
+	for _, dv := range batch.Views {
+		if dv.PostID == postID && dv.Day == day {
+			return dv.Views
+		}
+	}
+	return 0
+}
+
+func TestViews(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("should count a signed in viewer once per window", func(t *testing.T) {
+		now := start
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(1, 10)
+		now = now.Add(time.Minute * 30)
+		v.Record(1, 10)
+
+		batch := v.Drain()
+		if got := viewsOf(batch, 10, "2024-05-01"); got != 1 {
+			t.Errorf("got %d views, want 1", got)
+		}
+		if len(batch.Viewers) != 1 || batch.Viewers[0] != (DailyViewer{PostID: 10, Day: "2024-05-01", ViewerID: 1}) {
+			t.Errorf("got viewers %+v", batch.Viewers)
+		}
+
+		now = now.Add(time.Hour)
+		v.Record(1, 10)
+
+		if got := viewsOf(v.Drain(), 10, "2024-05-01"); got != 1 {
+			t.Errorf("got %d views after the window, want 1", got)
+		}
+	})
+
+	t.Run("should count every anonymous view", func(t *testing.T) {
+		now := start
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(0, 10, 11)
+		v.Record(0, 10)
+
+		batch := v.Drain()
+		if got := viewsOf(batch, 10, "2024-05-01"); got != 2 {
+			t.Errorf("got %d views, want 2", got)
+		}
+		if got := viewsOf(batch, 11, "2024-05-01"); got != 1 {
+			t.Errorf("got %d views, want 1", got)
+		}
+		if len(batch.Viewers) != 0 {
+			t.Errorf("got viewers %+v for anonymous views", batch.Viewers)
+		}
+	})
+
+	t.Run("should bucket views by UTC day", func(t *testing.T) {
+		now := time.Date(2024, 5, 1, 23, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(0, 10)
+
+		if got := viewsOf(v.Drain(), 10, "2024-05-01"); got != 1 {
+			t.Errorf("got %d views on the UTC day, want 1", got)
+		}
+	})
+
+	t.Run("should start a new batch after draining", func(t *testing.T) {
+		now := start
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(0, 10)
+		v.Drain()
+
+		if batch := v.Drain(); !batch.Empty() {
+			t.Errorf("got %+v, want an empty batch", batch)
+		}
+	})
+
+	t.Run("should forget viewers past the window when draining", func(t *testing.T) {
+		now := start
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(1, 10)
+		v.Record(2, 10)
+		now = now.Add(time.Hour)
+		v.Drain()
+
+		if len(v.seen) != 0 {
+			t.Errorf("got %d viewers remembered, want none", len(v.seen))
+		}
+	})
+
+	t.Run("should merge a restored batch into the next one", func(t *testing.T) {
+		now := start
+		v := newTestViews(time.Hour, &now)
+
+		v.Record(1, 10)
+		failed := v.Drain()
+
+		v.Record(0, 10)
+		v.Restore(failed)
+
+		batch := v.Drain()
+		if got := viewsOf(batch, 10, "2024-05-01"); got != 2 {
+			t.Errorf("got %d views, want 2", got)
+		}
+		if len(batch.Viewers) != 1 {
+			t.Errorf("got viewers %+v, want the restored one", batch.Viewers)
+		}
+	})
+}
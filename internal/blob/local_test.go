@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+
+	newStorage := func(t *testing.T) (*LocalStorage, string) {
+		t.Helper()
+
+		root := filepath.Join(t.TempDir(), "blobs")
+		s, err := NewLocalStorage(root, "http://localhost:8080/v1/media/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, root
+	}
+
+	t.Run("should read back what was put", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		if err := s.Put(ctx, "attachments/a.png", strings.NewReader("image"), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := s.Open(ctx, "attachments/a.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "image" {
+			t.Errorf("got %q, want %q", data, "image")
+		}
+	})
+
+	t.Run("should replace a blob without leaving temporary files", func(t *testing.T) {
+		s, root := newStorage(t)
+
+		_ = s.Put(ctx, "attachments/a.png", strings.NewReader("first"), "image/png")
+		_ = s.Put(ctx, "attachments/a.png", strings.NewReader("second"), "image/png")
+
+		entries, err := os.ReadDir(filepath.Join(root, "attachments"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("got %d files, want only the blob", len(entries))
+		}
+	})
+
+	t.Run("should not find deleted blobs", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		_ = s.Put(ctx, "attachments/a.png", strings.NewReader("image"), "image/png")
+		if err := s.Delete(ctx, "attachments/a.png"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Open(ctx, "attachments/a.png"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v, want %v", err, ErrNotFound)
+		}
+
+		// deleting twice is fine
+		if err := s.Delete(ctx, "attachments/a.png"); err != nil {
+			t.Errorf("got error %v deleting a missing blob", err)
+		}
+	})
+
+	t.Run("should reject keys escaping the root", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		for _, key := range []string{"../secret", "attachments/../../secret", "/etc/passwd", ".", ""} {
+			if _, err := s.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("got error %v for %q, want %v", err, key, ErrInvalidKey)
+			}
+			if err := s.Put(ctx, key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("got error %v putting %q, want %v", err, key, ErrInvalidKey)
+			}
+		}
+	})
+
+	t.Run("should build URLs under the base URL", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		if got, want := s.URL("attachments/a.png"), "http://localhost:8080/v1/media/attachments/a.png"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package cursor
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/signing"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered by creation time, the ID breaking
+// ties between items created at the same time.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	// Backward reads the page before the position instead of the one after.
+	Backward bool
+}
+
+const (
+	version     = 1
+	payloadSize = 1 + 1 + 8 + 8
+)
+
+// Signer turns cursors into opaque tokens and back. Tokens are signed, so
+// clients can't forge positions, and carry no meaning for them.
+type Signer struct {
+	signer *signing.Signer
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{signer: signing.NewSigner(secret)}
+}
+
+func (s *Signer) Encode(c Cursor) string {
+	buf := make([]byte, payloadSize)
+	buf[0] = version
+	if c.Backward {
+		buf[1] = 1
+	}
+	binary.BigEndian.PutUint64(buf[2:], uint64(c.CreatedAt.UnixMicro()))
+	binary.BigEndian.PutUint64(buf[10:], uint64(c.ID))
+
+	return s.signer.Sign(buf)
+}
+
+func (s *Signer) Decode(token string) (Cursor, error) {
+	payload, err := s.signer.Verify(token)
+	if err != nil || len(payload) != payloadSize || payload[0] != version || payload[1] > 1 {
+		return Cursor{}, ErrInvalid
+	}
+
+	return Cursor{
+		CreatedAt: time.UnixMicro(int64(binary.BigEndian.Uint64(payload[2:]))).UTC(),
+		ID:        int64(binary.BigEndian.Uint64(payload[10:])),
+		Backward:  payload[1] == 1,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package cursor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/signing"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner("secret")
+	c := Cursor{CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), ID: 42, Backward: true}
+
+	t.Run("should decode what it encoded", func(t *testing.T) {
+		got, err := signer.Decode(signer.Encode(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || got.Backward != c.Backward {
+			t.Errorf("got %+v, want %+v", got, c)
+		}
+	})
+
+	t.Run("should reject signed payloads that aren't cursors", func(t *testing.T) {
+		other := signing.NewSigner("secret")
+		payloads := [][]byte{
+			{},
+			{version, 0},
+			append([]byte{version + 1, 0}, make([]byte, 16)...),
+			append([]byte{version, 2}, make([]byte, 16)...),
+		}
+
+		for _, payload := range payloads {
+			if _, err := signer.Decode(other.Sign(payload)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("%v: got error %v, want %v", payload, err, ErrInvalid)
+			}
+		}
+	})
+}
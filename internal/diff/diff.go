@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"strings"
+)
+
+const separators = " \t\r\n"
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+type Edit struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines diffs a and b line by line.
+func Lines(a, b string) []Edit {
+	return Tokens(strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n"))
+}
+
+// Words diffs a and b word by word, keeping the whitespace attached to the
+// preceding word so the edits can be joined back into the original text.
+func Words(a, b string) []Edit {
+	return Tokens(splitWords(a), splitWords(b))
+}
+
+// Tokens returns the edits that turn a into b, based on their longest common
+// subsequence. Tokens are compared without their trailing whitespace, so the
+// last word or line still matches when the separator after it changes.
+// Consecutive edits with the same op are merged together.
+func Tokens(a, b []string) []Edit {
+	equal := func(i, j int) bool {
+		return strings.TrimRight(a[i], separators) == strings.TrimRight(b[j], separators)
+	}
+
+	// lcs[i][j] holds the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if equal(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []Edit
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case equal(i, j):
+			edits = appendEdit(edits, OpEqual, b[j])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = appendEdit(edits, OpDelete, a[i])
+			i++
+		default:
+			edits = appendEdit(edits, OpInsert, b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		edits = appendEdit(edits, OpDelete, a[i])
+	}
+
+	for ; j < len(b); j++ {
+		edits = appendEdit(edits, OpInsert, b[j])
+	}
+
+	return edits
+}
+
+func appendEdit(edits []Edit, op, text string) []Edit {
+	if text == "" {
+		return edits
+	}
+
+	if n := len(edits); n > 0 && edits[n-1].Op == op {
+		edits[n-1].Text += text
+		return edits
+	}
+
+	return append(edits, Edit{Op: op, Text: text})
+}
+
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	inSpace := false
+	for i, r := range s {
+		isSpace := strings.ContainsRune(separators, r)
+		if !isSpace && inSpace {
+			words = append(words, s[start:i])
+			start = i
+		}
+		inSpace = isSpace
+	}
+
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+
+	return words
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{
+			name: "should insert words",
+			a:    "hello world",
+			b:    "hello big world",
+			want: []Edit{{OpEqual, "hello "}, {OpInsert, "big "}, {OpEqual, "world"}},
+		},
+		{
+			name: "should delete words",
+			a:    "hello big world",
+			b:    "hello world",
+			want: []Edit{{OpEqual, "hello "}, {OpDelete, "big "}, {OpEqual, "world"}},
+		},
+		{
+			name: "should replace words",
+			a:    "hello old world",
+			b:    "hello new world",
+			want: []Edit{{OpEqual, "hello "}, {OpDelete, "old "}, {OpInsert, "new "}, {OpEqual, "world"}},
+		},
+		{
+			name: "should match the last word when the whitespace after it changes",
+			a:    "hello world",
+			b:    "hello world\n",
+			want: []Edit{{OpEqual, "hello world\n"}},
+		},
+		{
+			name: "should insert everything into empty text",
+			a:    "",
+			b:    "hello world",
+			want: []Edit{{OpInsert, "hello world"}},
+		},
+		{
+			name: "should delete everything from the text",
+			a:    "hello world",
+			b:    "",
+			want: []Edit{{OpDelete, "hello world"}},
+		},
+		{
+			name: "should have no edits for empty texts",
+			a:    "",
+			b:    "",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	t.Run("should replace a changed line", func(t *testing.T) {
+		got := Lines("one\ntwo\nthree\n", "one\n2\nthree\n")
+		want := []Edit{{OpEqual, "one\n"}, {OpDelete, "two\n"}, {OpInsert, "2\n"}, {OpEqual, "three\n"}}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+}
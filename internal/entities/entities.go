@@ -0,0 +1,167 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTagLength matches the size of the tags column.
+const MaxTagLength = 100
+
+// Entity is a token found in user content, Start and End are rune offsets
+// into the content and Text is the token without its prefix.
+type Entity struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Mentions returns the @username mentions in content, in order of
+// appearance. A mention has to start the content or follow a character that
+// can't be part of a username, so emails are not mistaken for mentions.
+// Usernames are mentioned in ASCII, a mention running into another letter
+// or digit isn't one.
+func Mentions(content string) []Entity {
+	runes := []rune(content)
+
+	found := []Entity{}
+	for _, e := range extract(content, '@', isUsernameRune) {
+		if (e.Start > 0 && isWordRune(runes[e.Start-1])) || (e.End < len(runes) && isWordRune(runes[e.End])) {
+			// josé@example.com and @josé don't mention example or jos
+			continue
+		}
+		found = append(found, e)
+	}
+	return found
+}
+
+// MentionedUsernames returns the unique usernames mentioned in content.
+func MentionedUsernames(content string) []string {
+	return unique(Mentions(content))
+}
+
+// Hashtags returns the #hashtags in content, in order of appearance. The
+// text of each entity is the tag as written, see NormalizeTag.
+func Hashtags(content string) []Entity {
+	found := []Entity{}
+	for _, e := range extract(content, '#', isTagRune) {
+		if strings.IndexFunc(e.Text, unicode.IsLetter) < 0 {
+			// #1 is a number, not a tag
+			continue
+		}
+		found = append(found, e)
+	}
+	return found
+}
+
+// NormalizeTag folds the compatibility forms and the case of a tag, so #Go,
+// #go and #ｇｏ are the same tag. It returns an empty string for tags that
+// are not valid.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(norm.NFKC.String(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return ""
+	}
+	return tag
+}
+
+// Tags merges the tags given by the client with the hashtags of content,
+// normalized and without duplicates.
+func Tags(given []string, content string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			return
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	for _, tag := range given {
+		add(tag)
+	}
+
+	for _, e := range Hashtags(content) {
+		add(e.Text)
+	}
+
+	return tags
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// URLs returns the http and https links in content, in order of appearance.
+// The text of each entity is the full URL, trailing punctuation excluded.
+func URLs(content string) []Entity {
+	found := []Entity{}
+	for _, loc := range urlPattern.FindAllStringIndex(content, -1) {
+		link := strings.TrimRight(content[loc[0]:loc[1]], ".,;:!?*_~")
+		start := utf8.RuneCountInString(content[:loc[0]])
+		found = append(found, Entity{
+			Text:  link,
+			Start: start,
+			End:   start + utf8.RuneCountInString(link),
+		})
+	}
+	return found
+}
+
+func extract(content string, prefix rune, valid func(rune) bool) []Entity {
+	runes := []rune(content)
+
+	var found []Entity
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != prefix || (i > 0 && valid(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && valid(runes[end]) {
+			end++
+		}
+
+		if end == i+1 {
+			continue
+		}
+
+		found = append(found, Entity{
+			Text:  string(runes[i+1 : end]),
+			Start: i,
+			End:   end,
+		})
+		i = end - 1
+	}
+
+	return found
+}
+
+func unique(found []Entity) []string {
+	seen := make(map[string]bool, len(found))
+	texts := []string{}
+	for _, e := range found {
+		if seen[e.Text] {
+			continue
+		}
+		seen[e.Text] = true
+		texts = append(texts, e.Text)
+	}
+	return texts
+}
+
+func isUsernameRune(r rune) bool {
+	return r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
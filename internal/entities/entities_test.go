@@ -0,0 +1,224 @@
+package entities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Entity
+	}{
+		{
+			name:    "should find a mention at the start",
+			content: "@alice hi",
+			want:    []Entity{{Text: "alice", Start: 0, End: 6}},
+		},
+		{
+			name:    "should find mentions in order with their offsets",
+			content: "hi @alice and @bob_2",
+			want: []Entity{
+				{Text: "alice", Start: 3, End: 9},
+				{Text: "bob_2", Start: 14, End: 20},
+			},
+		},
+		{
+			name:    "should stop mentions at punctuation",
+			content: "(@alice), @bob. @carol!",
+			want: []Entity{
+				{Text: "alice", Start: 1, End: 7},
+				{Text: "bob", Start: 10, End: 14},
+				{Text: "carol", Start: 16, End: 22},
+			},
+		},
+		{
+			name:    "should not mistake emails for mentions",
+			content: "write to a@b.com or alice_1@example.com",
+			want:    []Entity{},
+		},
+		{
+			name:    "should not find a mention in a lone @",
+			content: "meet @ 5, @ or @.",
+			want:    []Entity{},
+		},
+		{
+			name:    "should find the mention after a doubled @",
+			content: "@@alice",
+			want:    []Entity{{Text: "alice", Start: 1, End: 7}},
+		},
+		{
+			name:    "should keep every occurrence of the same mention",
+			content: "@alice @alice",
+			want: []Entity{
+				{Text: "alice", Start: 0, End: 6},
+				{Text: "alice", Start: 7, End: 13},
+			},
+		},
+		{
+			name:    "should count offsets in runes",
+			content: "¡olé! @alice",
+			want:    []Entity{{Text: "alice", Start: 6, End: 12}},
+		},
+		{
+			name:    "should not truncate mentions running into a non ASCII letter",
+			content: "@josé and josé@example.com",
+			want:    []Entity{},
+		},
+		{
+			name:    "should find mentions after non ASCII punctuation",
+			content: "«@alice»",
+			want:    []Entity{{Text: "alice", Start: 1, End: 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionedUsernames(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "should return each username once in order",
+			content: "@bob @alice @bob and @alice",
+			want:    []string{"bob", "alice"},
+		},
+		{
+			name:    "should keep usernames that only differ in case apart",
+			content: "@Alice @alice",
+			want:    []string{"Alice", "alice"},
+		},
+		{
+			name:    "should return an empty list without mentions",
+			content: "no one here, a@b.com",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MentionedUsernames(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Entity
+	}{
+		{
+			name:    "should find hashtags in order with their offsets",
+			content: "#go and #Rust_lang.",
+			want: []Entity{
+				{Text: "go", Start: 0, End: 3},
+				{Text: "Rust_lang", Start: 8, End: 18},
+			},
+		},
+		{
+			name:    "should reject tags without letters",
+			content: "issue #1 and #2024",
+			want:    []Entity{},
+		},
+		{
+			name:    "should accept tags with letters and digits",
+			content: "#web3",
+			want:    []Entity{{Text: "web3", Start: 0, End: 5}},
+		},
+		{
+			name:    "should not find tags inside words or urls",
+			content: "C# and example.com/#anchor",
+			want:    []Entity{{Text: "anchor", Start: 19, End: 26}},
+		},
+		{
+			name:    "should find non ASCII tags",
+			content: "¡#fútbol!",
+			want:    []Entity{{Text: "fútbol", Start: 1, End: 8}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hashtags(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{name: "should lowercase tags", tag: "Go", want: "go"},
+		{name: "should fold fullwidth forms", tag: "ｇｏ", want: "go"},
+		{name: "should drop the hash and spaces", tag: " #GoLang ", want: "golang"},
+		{name: "should reject empty tags", tag: " # ", want: ""},
+		{name: "should reject tags longer than the column", tag: strings.Repeat("a", MaxTagLength+1), want: ""},
+		{name: "should accept tags as long as the column", tag: strings.Repeat("a", MaxTagLength), want: strings.Repeat("a", MaxTagLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeTag(tt.tag); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		given   []string
+		content string
+		want    []string
+	}{
+		{
+			name:    "should merge the client tags with the hashtags of the content",
+			given:   []string{"news"},
+			content: "shipping #go today",
+			want:    []string{"news", "go"},
+		},
+		{
+			name:    "should fold every spelling of a tag into one",
+			given:   []string{"Go", "#go"},
+			content: "#GO and #ｇｏ",
+			want:    []string{"go"},
+		},
+		{
+			name:    "should drop invalid client tags",
+			given:   []string{"", "  ", "ok"},
+			content: "#1",
+			want:    []string{"ok"},
+		},
+		{
+			name: "should return an empty list without tags",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tags(tt.given, tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
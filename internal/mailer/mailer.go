@@ -3,9 +3,12 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "GopherSocial"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName             = "GopherSocial"
+	maxRetries           = 3
+	UserWelcomeTemplate  = "user_invitation.tmpl"
+	UserMentionTemplate  = "user_mention.tmpl"
+	NotificationTemplate = "notification.tmpl"
+	DigestTemplate       = "digest.tmpl"
 )
 
 //go:embed "templates"
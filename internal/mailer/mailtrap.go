@@ -36,7 +36,7 @@ func (m mailtrapClient) Send(templateFile, username string, email string, data a
 		return -1, err
 	}
 	body := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "body", data)
+	err = tmpl.ExecuteTemplate(body, "body", data)
 	if err != nil {
 		return 1, err
 	}
@@ -41,7 +41,7 @@ func (m *SendGridMailer) Send(templateFile, username string, email string, data
 		return -1, err
 	}
 	body := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "body", data)
+	err = tmpl.ExecuteTemplate(body, "body", data)
 	if err != nil {
 		return 1, err
 	}
@@ -0,0 +1,217 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+)
+
+// linkRel is set on every link, user content links are not endorsed.
+const linkRel = "nofollow ugc"
+
+var isUserKey = parser.NewContextKey()
+
+var md = goldmark.New(
+	goldmark.WithParser(parser.NewParser(
+		parser.WithBlockParsers(without(parser.DefaultBlockParsers(),
+			parser.NewATXHeadingParser(), parser.NewSetextHeadingParser(),
+			parser.NewThematicBreakParser(), parser.NewHTMLBlockParser())...),
+		parser.WithInlineParsers(without(parser.DefaultInlineParsers(),
+			parser.NewRawHTMLParser())...),
+		parser.WithParagraphTransformers(parser.DefaultParagraphTransformers()...),
+	)),
+	goldmark.WithExtensions(extension.Linkify),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(entityLinker{}, 100)),
+	),
+	goldmark.WithRendererOptions(goldhtml.WithHardWraps()),
+)
+
+// without drops the parsers outside of the supported subset. Their syntax is
+// kept as text, so HTML typed in a post shows up escaped instead of vanishing.
+func without(parsers []util.PrioritizedValue, unwanted ...any) []util.PrioritizedValue {
+	kept := []util.PrioritizedValue{}
+	for _, p := range parsers {
+		drop := false
+		for _, u := range unwanted {
+			if reflect.TypeOf(p.Value) == reflect.TypeOf(u) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// policy keeps the supported subset: emphasis, links, code, lists and
+// blockquotes. It backs up the parser, anything else that gets rendered, such
+// as images, is dropped.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "em", "strong", "code", "pre", "ul", "ol", "li", "blockquote")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^nofollow ugc$`)).OnElements("a")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(mention|hashtag)$`)).OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}()
+
+// Render converts Markdown content to sanitized HTML. Hashtags link to their
+// tag page and mentions to the profile of the user, when isUser reports that
+// the username exists.
+func Render(content string, isUser func(username string) bool) string {
+	pc := parser.NewContext()
+	pc.Set(isUserKey, isUser)
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf, parser.WithContext(pc)); err != nil {
+		// goldmark only fails on writer errors, which a buffer doesn't have
+		return "<p>" + html.EscapeString(content) + "</p>"
+	}
+
+	return policy.Sanitize(buf.String())
+}
+
+type entityLinker struct{}
+
+func (entityLinker) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	isUser, _ := pc.Get(isUserKey).(func(string) bool)
+	source := reader.Source()
+
+	var (
+		texts  []*ast.Text
+		images []*ast.Image
+	)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Link, *ast.AutoLink:
+			n.SetAttributeString("rel", []byte(linkRel))
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			images = append(images, n)
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			texts = append(texts, n)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// images are not supported, their alt text stands in for them
+	for _, img := range images {
+		parent := img.Parent()
+		for child := img.FirstChild(); child != nil; child = img.FirstChild() {
+			parent.InsertBefore(parent, img, child)
+		}
+		parent.RemoveChild(parent, img)
+	}
+
+	for _, t := range texts {
+		// the inline parser splits text on delimiters such as _, which
+		// usernames and tags can contain
+		if prev, ok := t.PreviousSibling().(*ast.Text); ok && joinable(prev, t) {
+			t.Segment = text.NewSegment(prev.Segment.Start, t.Segment.Stop)
+			t.Parent().RemoveChild(t.Parent(), prev)
+		}
+	}
+
+	for _, t := range texts {
+		if t.Parent() != nil {
+			linkEntities(t, source, isUser)
+		}
+	}
+}
+
+func joinable(prev, next *ast.Text) bool {
+	return prev.Segment.Stop == next.Segment.Start &&
+		prev.Segment.Padding == 0 && next.Segment.Padding == 0 &&
+		!prev.SoftLineBreak() && !prev.HardLineBreak() && !prev.IsRaw()
+}
+
+type link struct {
+	entities.Entity
+	class string
+	href  string
+}
+
+// linkEntities replaces the mentions and hashtags of a text node with links.
+func linkEntities(node *ast.Text, source []byte, isUser func(string) bool) {
+	value := string(node.Segment.Value(source))
+
+	var links []link
+	for _, e := range entities.Mentions(value) {
+		if isUser != nil && isUser(e.Text) {
+			links = append(links, link{Entity: e, class: "mention", href: "/users/" + url.PathEscape(e.Text)})
+		}
+	}
+	for _, e := range entities.Hashtags(value) {
+		if tag := entities.NormalizeTag(e.Text); tag != "" {
+			links = append(links, link{Entity: e, class: "hashtag", href: "/tags/" + url.PathEscape(tag)})
+		}
+	}
+
+	if len(links) == 0 {
+		return
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].Start < links[j].Start })
+
+	offsets := byteOffsets(value)
+	parent := node.Parent()
+	base := node.Segment.Start
+	pos := 0
+	for _, l := range links {
+		start, end := offsets[l.Start], offsets[l.End]
+		if start < pos {
+			continue
+		}
+
+		if start > pos {
+			parent.InsertBefore(parent, node, ast.NewTextSegment(text.NewSegment(base+pos, base+start)))
+		}
+
+		a := ast.NewLink()
+		a.Destination = []byte(l.href)
+		a.SetAttributeString("rel", []byte(linkRel))
+		a.SetAttributeString("class", []byte(l.class))
+		a.AppendChild(a, ast.NewTextSegment(text.NewSegment(base+start, base+end)))
+		parent.InsertBefore(parent, node, a)
+
+		pos = end
+	}
+
+	node.Segment = text.NewSegment(base+pos, node.Segment.Stop)
+}
+
+// byteOffsets maps the rune offsets of s, and its end, to byte offsets.
+func byteOffsets(s string) []int {
+	offsets := make([]int, 0, utf8.RuneCountInString(s)+1)
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(s))
+}
@@ -0,0 +1,109 @@
+package markdown
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	isUser := func(username string) bool { return username == "alice" }
+
+	t.Run("should render the supported subset", func(t *testing.T) {
+		got := Render("**bold** and `code` by @alice and @bob #Go", isUser)
+		want := `<p><strong>bold</strong> and <code>code</code> by ` +
+			`<a href="/users/alice" rel="nofollow ugc" class="mention">@alice</a> and @bob ` +
+			`<a href="/tags/go" rel="nofollow ugc" class="hashtag">#Go</a></p>` + "\n"
+
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("should not let scripts through", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			content string
+		}{
+			{"script tags", "<script>alert(1)</script>"},
+			{"javascript links", "[click](javascript:alert(1))"},
+			{"mixed case javascript links", "[click](JaVaScRiPt:alert(1))"},
+			{"javascript autolinks", "<javascript:alert(1)>"},
+			{"data links", "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)"},
+			{"javascript images", "![alt](javascript:alert(1))"},
+			{"event attributes", `<img src=x onerror="alert(1)">`},
+			{"event attributes on links", `<a href="https://example.com" onclick="alert(1)">x</a>`},
+			{"iframes", `<iframe src="https://example.com"></iframe>`},
+			{"styles", `<style>body { display: none }</style>`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := Render(tt.content, isUser)
+				assertSafe(t, got)
+			})
+		}
+	})
+}
+
+// The parser keeps HTML as text, the policy is what stands between anything
+// else the renderer outputs and the clients.
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "should drop script tags",
+			html: `<p>hi<script>alert(1)</script></p>`,
+			want: `<p>hi</p>`,
+		},
+		{
+			name: "should drop javascript links",
+			html: `<a href="javascript:alert(1)" rel="nofollow ugc">x</a>`,
+			want: `<a rel="nofollow ugc">x</a>`,
+		},
+		{
+			name: "should drop event attributes",
+			html: `<p onclick="alert(1)"><em onmouseover="alert(1)">x</em></p>`,
+			want: `<p><em>x</em></p>`,
+		},
+		{
+			name: "should drop images",
+			html: `<p><img src="x" onerror="alert(1)"></p>`,
+			want: `<p></p>`,
+		},
+		{
+			name: "should drop classes other than entities",
+			html: `<a href="/tags/go" rel="nofollow ugc" class="hashtag overlay">#go</a>`,
+			want: `<a href="/tags/go" rel="nofollow ugc">#go</a>`,
+		},
+		{
+			name: "should keep links nofollow",
+			html: `<a href="https://example.com">x</a>`,
+			want: `<a href="https://example.com" rel="nofollow">x</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Sanitize(tt.html)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			assertSafe(t, got)
+		})
+	}
+}
+
+// unsafeHTML matches the markup that runs scripts, HTML escaped into text is
+// fine.
+var unsafeHTML = regexp.MustCompile(`(?i)<(script|img|iframe|style)|<[^>]*\son\w+\s*=|href="(javascript|data):`)
+
+func assertSafe(t *testing.T, html string) {
+	t.Helper()
+
+	if unsafeHTML.MatchString(html) {
+		t.Errorf("got %q, which isn't safe", html)
+	}
+}
@@ -0,0 +1,116 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	// decoders of the accepted formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, so a small file can't
+	// expand into gigabytes of memory.
+	MaxPixels = 40_000_000
+
+	ThumbnailSize = 400
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions are too large")
+)
+
+// contentTypes are the accepted formats, keyed by their sniffed content type.
+var contentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Image is an uploaded image after validation.
+type Image struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Blurhash    string
+	// Thumbnail is a JPEG that fits in ThumbnailSize x ThumbnailSize.
+	Thumbnail []byte
+}
+
+// Process validates an uploaded image and derives its thumbnail and blurhash.
+// The format is sniffed from the content, the file name and the declared
+// content type are not trusted.
+func Process(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := contentTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	thumb := thumbnail(src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	// the hash only keeps a few colors, the thumbnail is plenty to compute it
+	hash, err := blurhash.Encode(4, 3, thumb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		ContentType: contentType,
+		Extension:   ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Blurhash:    hash,
+		Thumbnail:   buf.Bytes(),
+	}, nil
+}
+
+// thumbnail scales src down to fit in ThumbnailSize, keeping its aspect ratio.
+func thumbnail(src image.Image) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			w, h = ThumbnailSize, max(1, h*ThumbnailSize/w)
+		} else {
+			w, h = max(1, w*ThumbnailSize/h), ThumbnailSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// transparent images get a white background, JPEG has no alpha
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
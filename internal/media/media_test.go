@@ -0,0 +1,97 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		for y := range h {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("should derive a thumbnail and a blurhash", func(t *testing.T) {
+		img, err := Process(encodePNG(t, 800, 200))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/png" || img.Extension != ".png" {
+			t.Errorf("got %s %s, want a png", img.ContentType, img.Extension)
+		}
+		if img.Width != 800 || img.Height != 200 {
+			t.Errorf("got %dx%d, want 800x200", img.Width, img.Height)
+		}
+		if img.Blurhash == "" {
+			t.Error("got no blurhash")
+		}
+
+		thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != 100 {
+			t.Errorf("got a %dx%d thumbnail, want %dx100", b.Dx(), b.Dy(), ThumbnailSize)
+		}
+	})
+
+	t.Run("should not scale up small images", func(t *testing.T) {
+		img, err := Process(encodePNG(t, 10, 20))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := thumb.Bounds(); b.Dx() != 10 || b.Dy() != 20 {
+			t.Errorf("got a %dx%d thumbnail, want 10x20", b.Dx(), b.Dy())
+		}
+	})
+
+	t.Run("should reject what isn't an image", func(t *testing.T) {
+		for _, data := range [][]byte{
+			[]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+			[]byte("%PDF-1.4"),
+			// a PNG signature with nothing behind it
+			[]byte("\x89PNG\r\n\x1a\n"),
+		} {
+			if _, err := Process(data); !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("got error %v for %q, want %v", err, data, ErrUnsupportedFormat)
+			}
+		}
+	})
+
+	t.Run("should reject images too large to decode", func(t *testing.T) {
+		// only the header is read before the dimensions are checked
+		data := encodePNG(t, 1, 1)
+		// IHDR width and height, 10000x10000, and the checksum of the chunk
+		copy(data[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10})
+		binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+		if _, err := Process(data); !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("got error %v, want %v", err, ErrImageTooLarge)
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("the address is not allowed")
+	ErrNotHTML          = errors.New("the page is not HTML")
+)
+
+// Card is the metadata a page describes itself with.
+type Card struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+}
+
+type Config struct {
+	Timeout  time.Duration
+	MaxBytes int64
+	// AllowPrivate lets the fetcher reach private and loopback addresses,
+	// only tests against a local server should need it.
+	AllowPrivate bool
+}
+
+// Fetcher fetches pages to build preview cards. URLs come from user content,
+// so every connection is checked against the address it actually dials,
+// after DNS resolution and on every redirect.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+const maxRedirects = 3
+
+func NewFetcher(cfg Config) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if cfg.AllowPrivate {
+				return nil
+			}
+			return checkAddress(address)
+		},
+	}
+
+	transport := &http.Transport{
+		// never go through a proxy from the environment, it would be the
+		// one dialing and the address check would not apply
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errors.New("too many redirects")
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		maxBytes: cfg.MaxBytes,
+	}
+}
+
+// Fetch downloads the page at rawURL and extracts its OpenGraph and Twitter
+// card metadata, falling back to the title and description of the page.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Card, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "GopherSocialBot/1.0 (link preview)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAddress) {
+			return nil, ErrForbiddenAddress
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	card := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	card.URL = rawURL
+	return card, nil
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// reserved are the non public ranges netip doesn't classify.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return ErrForbiddenAddress
+	}
+
+	for _, prefix := range reserved {
+		if prefix.Contains(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// parse reads the metadata in the head of a page. base resolves relative
+// image URLs.
+func parse(r io.Reader, base *url.URL) *Card {
+	meta := make(map[string]string)
+	var title string
+
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return card(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				key, content := metaAttrs(z)
+				if key != "" && meta[key] == "" {
+					meta[key] = strings.TrimSpace(content)
+				}
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "body":
+				// metadata lives in the head, no need to read the page
+				return card(meta, title, base)
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return card(meta, title, base)
+			}
+		}
+	}
+}
+
+// metaAttrs returns the name or property of a meta tag and its content.
+func metaAttrs(z *html.Tokenizer) (string, string) {
+	var key, content string
+	for {
+		name, val, more := z.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(string(val))
+			}
+		case "content":
+			content = string(val)
+		}
+		if !more {
+			return key, content
+		}
+	}
+}
+
+func card(meta map[string]string, title string, base *url.URL) *Card {
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := meta[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	c := &Card{
+		Title:       truncate(first("og:title", "twitter:title"), 300),
+		Description: truncate(first("og:description", "twitter:description", "description"), 1000),
+		SiteName:    truncate(first("og:site_name"), 100),
+	}
+
+	if c.Title == "" {
+		c.Title = truncate(title, 300)
+	}
+
+	if image := first("og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			c.ImageURL = u.String()
+		}
+	}
+
+	return c
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
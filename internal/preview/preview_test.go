@@ -0,0 +1,126 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Gophers &amp; friends">
+<meta name="twitter:description" content="All about gophers">
+<meta property="og:image" content="/img/gopher.png">
+<meta property="og:site_name" content="Gopher News">
+</head><body><meta property="og:title" content="Ignored"></body></html>`
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, page)
+		case "/plain":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head><title> Just a title </title></head></html>")
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, "{}")
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", 4096)+`<meta property="og:title" content="Too far">`)
+		case "/redirect":
+			http.Redirect(w, r, "/redirect", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fetcher := NewFetcher(Config{Timeout: 2 * time.Second, MaxBytes: 1024, AllowPrivate: true})
+	ctx := context.Background()
+
+	t.Run("should extract the card metadata", func(t *testing.T) {
+		card, err := fetcher.Fetch(ctx, srv.URL+"/article")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Card{
+			URL:         srv.URL + "/article",
+			Title:       "Gophers & friends",
+			Description: "All about gophers",
+			ImageURL:    srv.URL + "/img/gopher.png",
+			SiteName:    "Gopher News",
+		}
+		if *card != want {
+			t.Errorf("got %+v, want %+v", *card, want)
+		}
+	})
+
+	t.Run("should fall back to the page title", func(t *testing.T) {
+		card, err := fetcher.Fetch(ctx, srv.URL+"/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if card.Title != "Just a title" {
+			t.Errorf("got title %q", card.Title)
+		}
+	})
+
+	t.Run("should reject pages that are not HTML", func(t *testing.T) {
+		if _, err := fetcher.Fetch(ctx, srv.URL+"/json"); !errors.Is(err, ErrNotHTML) {
+			t.Errorf("got error %v, want %v", err, ErrNotHTML)
+		}
+	})
+
+	t.Run("should stop reading at the size limit", func(t *testing.T) {
+		card, err := fetcher.Fetch(ctx, srv.URL+"/big")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if card.Title != "" {
+			t.Errorf("got title %q past the size limit", card.Title)
+		}
+	})
+
+	t.Run("should give up on redirect loops", func(t *testing.T) {
+		if _, err := fetcher.Fetch(ctx, srv.URL+"/redirect"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("should not reach private addresses", func(t *testing.T) {
+		guarded := NewFetcher(Config{Timeout: 2 * time.Second, MaxBytes: 1024})
+
+		if _, err := guarded.Fetch(ctx, srv.URL+"/article"); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("got error %v, want %v", err, ErrForbiddenAddress)
+		}
+	})
+}
+
+func TestCheckAddress(t *testing.T) {
+	forbidden := []string{
+		"127.0.0.1:80", "10.1.2.3:80", "172.16.0.1:80", "192.168.1.1:443", "169.254.169.254:80",
+		"100.64.0.1:80", "0.0.0.0:80", "[::1]:80", "[fd00::1]:80", "[fe80::1]:80", "[::ffff:127.0.0.1]:80",
+	}
+	for _, addr := range forbidden {
+		if err := checkAddress(addr); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: got %v, want %v", addr, err, ErrForbiddenAddress)
+		}
+	}
+
+	for _, addr := range []string{"93.184.216.34:443", "[2606:2800:220:1:248:1893:25c8:1946]:443"} {
+		if err := checkAddress(addr); err != nil {
+			t.Errorf("%s: got %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Candidate is a post that may make it into a ranked feed, with the signals
+// scorers work from.
+type Candidate struct {
+	PostID    int64
+	AuthorID  int64
+	CreatedAt time.Time
+	Comments  int
+	// Reactions counts the users who reacted to the post, voting in its
+	// poll is the only reaction so far.
+	Reactions int
+	// Affinity counts the recent interactions of the viewer with the author.
+	Affinity int
+}
+
+// Explanation breaks down the score of a post, for debugging rankings.
+type Explanation struct {
+	Score      float64 `json:"score"`
+	Recency    float64 `json:"recency"`
+	Engagement float64 `json:"engagement"`
+	Affinity   float64 `json:"affinity"`
+	// Demoted is set when the post was pushed down to keep an author from
+	// filling the feed.
+	Demoted bool `json:"demoted,omitempty"`
+}
+
+// Scorer scores candidates, higher comes first.
+type Scorer interface {
+	Score(now time.Time, c Candidate) Explanation
+}
+
+// Ranked is a candidate in its ranked position.
+type Ranked struct {
+	Candidate
+	Explanation Explanation
+}
+
+// Decay scores posts by how recent they are, losing half their weight every
+// HalfLife, boosted by the engagement they got and by the affinity of the
+// viewer with their author. Counts are damped with a logarithm so a viral
+// post doesn't beat everything else for days.
+type Decay struct {
+	HalfLife         time.Duration
+	EngagementWeight float64
+	AffinityWeight   float64
+}
+
+func DefaultScorer() Scorer {
+	return Decay{
+		HalfLife:         12 * time.Hour,
+		EngagementWeight: 0.5,
+		AffinityWeight:   0.8,
+	}
+}
+
+func (d Decay) Score(now time.Time, c Candidate) Explanation {
+	age := max(now.Sub(c.CreatedAt), 0)
+
+	e := Explanation{
+		Recency:    math.Pow(0.5, age.Hours()/d.HalfLife.Hours()),
+		Engagement: math.Log1p(float64(2*c.Comments + c.Reactions)),
+		Affinity:   math.Log1p(float64(c.Affinity)),
+	}
+	e.Score = e.Recency * (1 + d.EngagementWeight*e.Engagement + d.AffinityWeight*e.Affinity)
+
+	return e
+}
+
+// Rank orders candidates by score. An author never gets more than
+// maxConsecutive posts in a row while posts of others are left, the best of
+// those goes in between.
+func Rank(scorer Scorer, now time.Time, candidates []Candidate, maxConsecutive int) []Ranked {
+	pending := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		pending[i] = Ranked{Candidate: c, Explanation: scorer.Score(now, c)}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].Explanation.Score != pending[j].Explanation.Score {
+			return pending[i].Explanation.Score > pending[j].Explanation.Score
+		}
+		return pending[i].PostID > pending[j].PostID
+	})
+
+	ranked := make([]Ranked, 0, len(pending))
+	var lastAuthor int64
+	streak := 0
+	for len(pending) > 0 {
+		pick := 0
+		if streak >= maxConsecutive {
+			for i, r := range pending {
+				if r.AuthorID != lastAuthor {
+					pick = i
+					break
+				}
+			}
+		}
+
+		r := pending[pick]
+		pending = append(pending[:pick], pending[pick+1:]...)
+
+		if r.AuthorID == lastAuthor {
+			streak++
+		} else {
+			lastAuthor, streak = r.AuthorID, 1
+		}
+
+		if pick > 0 {
+			// everything skipped over is demoted by one place
+			for i := 0; i < pick; i++ {
+				pending[i].Explanation.Demoted = true
+			}
+		}
+
+		ranked = append(ranked, r)
+	}
+
+	return ranked
+}
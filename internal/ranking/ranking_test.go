@@ -0,0 +1,44 @@
+package ranking
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRank(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scorer := DefaultScorer()
+
+	t.Run("should prefer recent and engaging posts", func(t *testing.T) {
+		old := Candidate{PostID: 1, AuthorID: 1, CreatedAt: now.Add(-48 * time.Hour), Comments: 3}
+		fresh := Candidate{PostID: 2, AuthorID: 2, CreatedAt: now.Add(-time.Hour)}
+		busy := Candidate{PostID: 3, AuthorID: 3, CreatedAt: now.Add(-time.Hour), Comments: 10}
+
+		ranked := Rank(scorer, now, []Candidate{old, fresh, busy}, 2)
+
+		got := []int64{ranked[0].PostID, ranked[1].PostID, ranked[2].PostID}
+		want := []int64{3, 2, 1}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got order %v, want %v", got, want)
+			}
+		}
+	})
+
+	t.Run("should not let an author fill the feed", func(t *testing.T) {
+		var candidates []Candidate
+		for i := range 4 {
+			candidates = append(candidates, Candidate{PostID: int64(i + 1), AuthorID: 1, CreatedAt: now.Add(-time.Duration(i) * time.Minute), Comments: 20})
+		}
+		candidates = append(candidates, Candidate{PostID: 10, AuthorID: 2, CreatedAt: now.Add(-24 * time.Hour)})
+
+		ranked := Rank(scorer, now, candidates, 2)
+
+		if ranked[2].AuthorID != 2 {
+			t.Errorf("got author %d third, want the other author", ranked[2].AuthorID)
+		}
+		if !ranked[3].Explanation.Demoted {
+			t.Error("expected the skipped post to be marked as demoted")
+		}
+	})
+}
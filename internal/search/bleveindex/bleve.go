@@ -0,0 +1,444 @@
+// Package bleveindex is a search index embedded in the API process, stored
+// on local disk with Bleve. It needs no extra infrastructure, which suits
+// small self-hosted deployments.
+package bleveindex
+
+import (
+	"context"
+	"errors"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
+	"github.com/blevesearch/bleve/v2/mapping"
+	blevesearch "github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"ontopsolutions.net/gasperlf/social/internal/search"
+)
+
+const (
+	// wordsAnalyzer splits on anything that is not a letter or a digit, like
+	// search.Parse does, without stemming
+	wordsAnalyzer = "words"
+
+	// fuzzyMinLength is the length from which words match with a typo
+	fuzzyMinLength = 4
+
+	facetSize    = 10
+	snippetWords = 30
+)
+
+// Index keeps posts and users in two Bleve indexes under one directory.
+type Index struct {
+	posts bleve.Index
+	users bleve.Index
+}
+
+var _ search.Index = (*Index)(nil)
+
+// Open opens the index at dir, creating it when it doesn't exist.
+func Open(dir string) (*Index, error) {
+	posts, err := openOrCreate(filepath.Join(dir, "posts"), postMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := openOrCreate(filepath.Join(dir, "users"), userMapping)
+	if err != nil {
+		posts.Close()
+		return nil, err
+	}
+
+	return &Index{posts: posts, users: users}, nil
+}
+
+// openOrCreate opens the index at path, or creates it with the mapping built
+// by newMapping.
+func openOrCreate(path string, newMapping func() (mapping.IndexMapping, error)) (bleve.Index, error) {
+	idx, err := bleve.Open(path)
+	if !errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return idx, err
+	}
+
+	m, err := newMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return bleve.New(path, m)
+}
+
+func (i *Index) Close() error {
+	return errors.Join(i.posts.Close(), i.users.Close())
+}
+
+// postDoc is how a post is stored, IDs are strings to be matched as terms.
+type postDoc struct {
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Tags       []string   `json:"tags"`
+	Author     string     `json:"author"`
+	AuthorID   string     `json:"author_id"`
+	Visibility string     `json:"visibility"`
+	Mentions   []string   `json:"mentions"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Flagged    bool       `json:"flagged"`
+}
+
+type userDoc struct {
+	Username string `json:"username"`
+}
+
+func addWordsAnalyzer(m *mapping.IndexMappingImpl) error {
+	err := m.AddCustomTokenizer(wordsAnalyzer, map[string]interface{}{
+		"type":   regexp.Name,
+		"regexp": `[\p{L}\p{N}]+`,
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.AddCustomAnalyzer(wordsAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     wordsAnalyzer,
+		"token_filters": []string{lowercase.Name},
+	})
+}
+
+func textField(analyzer string, store bool) *mapping.FieldMapping {
+	f := bleve.NewTextFieldMapping()
+	f.Analyzer = analyzer
+	f.Store = store
+	f.IncludeTermVectors = store
+	return f
+}
+
+func keywordField() *mapping.FieldMapping {
+	f := bleve.NewKeywordFieldMapping()
+	f.Store = false
+	f.IncludeInAll = false
+	return f
+}
+
+func postMapping() (mapping.IndexMapping, error) {
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("title", textField(en.AnalyzerName, true))
+	doc.AddFieldMappingsAt("content", textField(en.AnalyzerName, true))
+	doc.AddFieldMappingsAt("tags", keywordField())
+	doc.AddFieldMappingsAt("author", keywordField())
+	doc.AddFieldMappingsAt("author_id", keywordField())
+	doc.AddFieldMappingsAt("visibility", keywordField())
+	doc.AddFieldMappingsAt("mentions", keywordField())
+
+	createdAt := bleve.NewDateTimeFieldMapping()
+	createdAt.Store = false
+	doc.AddFieldMappingsAt("created_at", createdAt)
+
+	expiresAt := bleve.NewDateTimeFieldMapping()
+	expiresAt.Store = false
+	doc.AddFieldMappingsAt("expires_at", expiresAt)
+
+	flagged := bleve.NewBooleanFieldMapping()
+	flagged.Store = false
+	doc.AddFieldMappingsAt("flagged", flagged)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = keyword.Name
+	return m, nil
+}
+
+func userMapping() (mapping.IndexMapping, error) {
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("username", textField(wordsAnalyzer, false))
+
+	m := bleve.NewIndexMapping()
+	if err := addWordsAnalyzer(m); err != nil {
+		return nil, err
+	}
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = wordsAnalyzer
+	return m, nil
+}
+
+func docID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (i *Index) IndexPosts(ctx context.Context, docs ...search.PostDocument) error {
+	batch := i.posts.NewBatch()
+	for _, d := range docs {
+		mentions := make([]string, len(d.Mentions))
+		for j, id := range d.Mentions {
+			mentions[j] = docID(id)
+		}
+
+		err := batch.Index(docID(d.ID), postDoc{
+			Title:      d.Title,
+			Content:    d.Content,
+			Tags:       d.Tags,
+			Author:     d.Author,
+			AuthorID:   docID(d.AuthorID),
+			Visibility: d.Visibility,
+			Mentions:   mentions,
+			CreatedAt:  d.CreatedAt,
+			ExpiresAt:  d.ExpiresAt,
+			Flagged:    d.Flagged,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return i.posts.Batch(batch)
+}
+
+func (i *Index) DeletePosts(ctx context.Context, ids ...int64) error {
+	return deleteDocs(i.posts, ids)
+}
+
+func (i *Index) IndexUsers(ctx context.Context, docs ...search.UserDocument) error {
+	batch := i.users.NewBatch()
+	for _, d := range docs {
+		if err := batch.Index(docID(d.ID), userDoc{Username: d.Username}); err != nil {
+			return err
+		}
+	}
+
+	return i.users.Batch(batch)
+}
+
+func (i *Index) DeleteUsers(ctx context.Context, ids ...int64) error {
+	return deleteDocs(i.users, ids)
+}
+
+func deleteDocs(idx bleve.Index, ids []int64) error {
+	batch := idx.NewBatch()
+	for _, id := range ids {
+		batch.Delete(docID(id))
+	}
+
+	return idx.Batch(batch)
+}
+
+// SearchPosts matches the terms in titles and contents, titles weigh double.
+// Words long enough match with a typo.
+func (i *Index) SearchPosts(ctx context.Context, q search.PostQuery) (*search.PostResults, error) {
+	fields := map[string]float64{"title": 2, "content": 1}
+
+	b := bleve.NewBooleanQuery()
+	addTerms(b, q.Query, fields, true)
+	b.AddFilter(visibleTo(q.Viewer))
+
+	// expired posts are gone until they are purged from the store
+	expired := bleve.NewDateRangeQuery(time.Time{}, time.Now())
+	expired.SetField("expires_at")
+	b.AddMustNot(expired)
+
+	// left out here rather than by the store, so totals and facets agree
+	// with the hits
+	if q.Viewer.HideFlagged {
+		b.AddMustNot(flaggedByOthers(q.Viewer))
+	}
+
+	if q.Tag != "" {
+		b.AddFilter(termQuery("tags", q.Tag))
+	}
+	if q.Author != "" {
+		b.AddFilter(termQuery("author", q.Author))
+	}
+
+	req := bleve.NewSearchRequestOptions(b, q.Limit, q.Offset, false)
+	req.Fields = []string{"content"}
+	req.Highlight = bleve.NewHighlight()
+	req.Highlight.AddField("content")
+	req.AddFacet("tags", bleve.NewFacetRequest("tags", facetSize))
+	req.AddFacet("authors", bleve.NewFacetRequest("author", facetSize))
+
+	res, err := i.posts.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &search.PostResults{
+		Hits:  make([]search.Hit, 0, len(res.Hits)),
+		Total: int(res.Total),
+		Facets: search.Facets{
+			Tags:    facetTerms(res.Facets["tags"]),
+			Authors: facetTerms(res.Facets["authors"]),
+		},
+	}
+
+	for _, h := range res.Hits {
+		id, err := strconv.ParseInt(h.ID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		snippet := strings.Join(h.Fragments["content"], " … ")
+		if snippet == "" {
+			content, _ := h.Fields["content"].(string)
+			snippet = html.EscapeString(leadingWords(content, snippetWords))
+		}
+
+		results.Hits = append(results.Hits, search.Hit{ID: id, Score: h.Score, Snippet: snippet})
+	}
+
+	return results, nil
+}
+
+func (i *Index) SearchUsers(ctx context.Context, q search.UserQuery) (*search.UserResults, error) {
+	b := bleve.NewBooleanQuery()
+	addTerms(b, q.Query, map[string]float64{"username": 1}, true)
+
+	req := bleve.NewSearchRequestOptions(b, q.Limit, q.Offset, false)
+
+	res, err := i.users.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &search.UserResults{
+		Hits:  make([]search.Hit, 0, len(res.Hits)),
+		Total: int(res.Total),
+	}
+
+	for _, h := range res.Hits {
+		id, err := strconv.ParseInt(h.ID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		results.Hits = append(results.Hits, search.Hit{ID: id, Score: h.Score})
+	}
+
+	return results, nil
+}
+
+// addTerms adds the terms of q to b, each must match one of the fields.
+func addTerms(b *query.BooleanQuery, q search.Query, fields map[string]float64, fuzzy bool) {
+	for _, t := range q.Terms {
+		matches := make([]query.Query, 0, len(fields))
+		for field, boost := range fields {
+			matches = append(matches, fieldQuery(t, field, boost, fuzzy))
+		}
+
+		if t.Exclude {
+			b.AddMustNot(matches...)
+		} else {
+			b.AddMust(bleve.NewDisjunctionQuery(matches...))
+		}
+	}
+}
+
+// fieldQuery matches a term on field. The words of a phrase must follow each
+// other, the last word of a prefix term only has to start the word there.
+func fieldQuery(t search.Term, field string, boost float64, fuzzy bool) query.Query {
+	words := t.Words
+	var prefix *query.PrefixQuery
+	if t.Prefix {
+		prefix = bleve.NewPrefixQuery(words[len(words)-1])
+		prefix.SetField(field)
+		prefix.SetBoost(boost)
+		words = words[:len(words)-1]
+	}
+
+	var match query.Query
+	switch {
+	case len(words) == 1:
+		m := bleve.NewMatchQuery(words[0])
+		m.SetField(field)
+		m.SetBoost(boost)
+		if fuzzy && utf8.RuneCountInString(words[0]) >= fuzzyMinLength {
+			m.SetFuzziness(1)
+		}
+		match = m
+	case len(words) > 1:
+		m := bleve.NewMatchPhraseQuery(strings.Join(words, " "))
+		m.SetField(field)
+		m.SetBoost(boost)
+		match = m
+	}
+
+	switch {
+	case prefix == nil:
+		return match
+	case match == nil:
+		return prefix
+	default:
+		return bleve.NewConjunctionQuery(match, prefix)
+	}
+}
+
+// visibleTo mirrors the visibility rules of the store for the posts in the
+// index, which are all published.
+func visibleTo(v search.Viewer) query.Query {
+	rules := []query.Query{
+		termQuery("visibility", "public"),
+		termQuery("author_id", docID(v.ID)),
+		bleve.NewConjunctionQuery(termQuery("visibility", "mentioned"), termQuery("mentions", docID(v.ID))),
+	}
+
+	if len(v.Following) > 0 {
+		authors := make([]query.Query, len(v.Following))
+		for i, id := range v.Following {
+			authors[i] = termQuery("author_id", docID(id))
+		}
+		rules = append(rules, bleve.NewConjunctionQuery(termQuery("visibility", "followers"), bleve.NewDisjunctionQuery(authors...)))
+	}
+
+	return bleve.NewDisjunctionQuery(rules...)
+}
+
+// flaggedByOthers matches the flagged posts the viewer didn't write, like
+// displayableTo in the store.
+func flaggedByOthers(v search.Viewer) query.Query {
+	flagged := bleve.NewBoolFieldQuery(true)
+	flagged.SetField("flagged")
+
+	b := bleve.NewBooleanQuery()
+	b.AddMust(flagged)
+	b.AddMustNot(termQuery("author_id", docID(v.ID)))
+	return b
+}
+
+func termQuery(field, term string) query.Query {
+	q := bleve.NewTermQuery(term)
+	q.SetField(field)
+	return q
+}
+
+func facetTerms(f *blevesearch.FacetResult) []search.FacetTerm {
+	terms := []search.FacetTerm{}
+	if f == nil || f.Terms == nil {
+		return terms
+	}
+
+	for _, t := range f.Terms.Terms() {
+		terms = append(terms, search.FacetTerm{Term: t.Term, Count: t.Count})
+	}
+
+	return terms
+}
+
+func leadingWords(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:n], " ") + " …"
+}
@@ -0,0 +1,182 @@
+package bleveindex
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/search"
+)
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+
+	idx, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	err = idx.IndexPosts(ctx,
+		search.PostDocument{ID: 1, AuthorID: 10, Author: "ana", Title: "Running a marathon", Content: "Tips for <b>runners</b> training for their first marathon", Tags: []string{"sport"}, Visibility: "public", CreatedAt: now},
+		search.PostDocument{ID: 2, AuthorID: 11, Author: "bob", Title: "Go generics", Content: "Type parameters in practice", Tags: []string{"go"}, Visibility: "public", CreatedAt: now},
+		search.PostDocument{ID: 3, AuthorID: 11, Author: "bob", Title: "Marathon training plan", Content: "Only for my followers", Tags: []string{"sport"}, Visibility: "followers", CreatedAt: now},
+		search.PostDocument{ID: 4, AuthorID: 12, Author: "eve", Title: "Marathon secrets", Content: "For mentioned users", Visibility: "mentioned", Mentions: []int64{20}, CreatedAt: now},
+		search.PostDocument{ID: 5, AuthorID: 10, Author: "ana", Title: "Marathon flash sale", Content: "Gone already", Visibility: "public", CreatedAt: now, ExpiresAt: &expired},
+		search.PostDocument{ID: 6, AuthorID: 10, Author: "ana", Title: "Marathon today", Content: "Still running", Tags: []string{"sport"}, Visibility: "public", CreatedAt: now, ExpiresAt: &later},
+		search.PostDocument{ID: 7, AuthorID: 13, Author: "zoe", Title: "Marathon injuries", Content: "Graphic pictures", Tags: []string{"health"}, Visibility: "followers", CreatedAt: now, Flagged: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(t *testing.T, q string, pq search.PostQuery) *search.PostResults {
+		t.Helper()
+
+		parsed, err := search.Parse(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pq.Query = parsed
+		pq.Limit = 10
+
+		res, err := idx.SearchPosts(ctx, pq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	ids := func(res *search.PostResults) []int64 {
+		ids := make([]int64, len(res.Hits))
+		for i, h := range res.Hits {
+			ids[i] = h.ID
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	t.Run("should only find what the viewer can see", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			viewer search.Viewer
+			want   []int64
+		}{
+			{name: "anonymous", viewer: search.Viewer{}, want: []int64{1, 6}},
+			{name: "follower", viewer: search.Viewer{ID: 30, Following: []int64{11}}, want: []int64{1, 3, 6}},
+			{name: "mentioned", viewer: search.Viewer{ID: 20}, want: []int64{1, 4, 6}},
+			{name: "author", viewer: search.Viewer{ID: 12}, want: []int64{1, 4, 6}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := ids(find(t, "marathon", search.PostQuery{Viewer: tt.viewer})); !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("should leave out flagged posts for viewers hiding them", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			viewer search.Viewer
+			want   []int64
+			tags   int
+		}{
+			{name: "showing them", viewer: search.Viewer{ID: 30, Following: []int64{13}}, want: []int64{1, 6, 7}, tags: 2},
+			{name: "hiding them", viewer: search.Viewer{ID: 30, Following: []int64{13}, HideFlagged: true}, want: []int64{1, 6}, tags: 1},
+			{name: "author hiding them", viewer: search.Viewer{ID: 13, HideFlagged: true}, want: []int64{1, 6, 7}, tags: 2},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				res := find(t, "marathon", search.PostQuery{Viewer: tt.viewer})
+				if got := ids(res); !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+				if res.Total != len(tt.want) || len(res.Facets.Tags) != tt.tags {
+					t.Errorf("got total %d and tags %v", res.Total, res.Facets.Tags)
+				}
+			})
+		}
+	})
+
+	t.Run("should match stems, typos, prefixes and phrases", func(t *testing.T) {
+		tests := []struct {
+			q    string
+			want []int64
+		}{
+			{q: "runs", want: []int64{1, 6}},
+			{q: "marathn", want: []int64{1, 6}},
+			{q: "gener*", want: []int64{2}},
+			{q: `"type parameters"`, want: []int64{2}},
+			{q: `"parameters type"`, want: []int64{}},
+			{q: "marathon -tips", want: []int64{6}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.q, func(t *testing.T) {
+				if got := ids(find(t, tt.q, search.PostQuery{})); !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("should count facets and filter by them", func(t *testing.T) {
+		res := find(t, "marathon", search.PostQuery{Viewer: search.Viewer{ID: 30, Following: []int64{11}}})
+
+		if len(res.Facets.Tags) != 1 || res.Facets.Tags[0] != (search.FacetTerm{Term: "sport", Count: 3}) {
+			t.Errorf("got tags %v", res.Facets.Tags)
+		}
+
+		if got := ids(find(t, "marathon", search.PostQuery{Author: "ana"})); !slices.Equal(got, []int64{1, 6}) {
+			t.Errorf("got %v, want [1 6]", got)
+		}
+	})
+
+	t.Run("should escape snippets", func(t *testing.T) {
+		res := find(t, "tips", search.PostQuery{})
+		if len(res.Hits) != 1 {
+			t.Fatalf("got %d hits, want 1", len(res.Hits))
+		}
+
+		snippet := res.Hits[0].Snippet
+		if !strings.Contains(snippet, "<mark>Tips</mark>") || !strings.Contains(snippet, "&lt;b&gt;") {
+			t.Errorf("got snippet %q", snippet)
+		}
+	})
+
+	t.Run("should forget deleted posts", func(t *testing.T) {
+		if err := idx.DeletePosts(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ids(find(t, "marathon", search.PostQuery{})); !slices.Equal(got, []int64{6}) {
+			t.Errorf("got %v, want [6]", got)
+		}
+	})
+
+	t.Run("should find users by the start of their username", func(t *testing.T) {
+		if err := idx.IndexUsers(ctx, search.UserDocument{ID: 1, Username: "john_doe42"}, search.UserDocument{ID: 2, Username: "jane"}); err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, _ := search.Parse("doe*")
+		res, err := idx.SearchUsers(ctx, search.UserQuery{Query: parsed, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Hits) != 1 || res.Hits[0].ID != 1 {
+			t.Errorf("got %v, want user 1", res.Hits)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// PostDocument is what an index keeps of a published post. Visibility,
+// Mentions and ExpiresAt let it leave out what a viewer can't see, Flagged
+// what they chose to hide.
+type PostDocument struct {
+	ID         int64
+	AuthorID   int64
+	Author     string
+	Title      string
+	Content    string
+	Tags       []string
+	Visibility string
+	Mentions   []int64
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	// Flagged is set on posts marked sensitive or behind a content warning
+	Flagged bool
+}
+
+type UserDocument struct {
+	ID       int64
+	Username string
+}
+
+// Viewer is who searches. An index only returns public posts, the posts of
+// the viewer, the followers only posts of the users they follow and the posts
+// mentioning them.
+type Viewer struct {
+	ID        int64
+	Following []int64
+	// HideFlagged leaves out the flagged posts of others
+	HideFlagged bool
+}
+
+type PostQuery struct {
+	Query  Query
+	Viewer Viewer
+	// Tag and Author narrow the results to a facet, Author is a username
+	Tag    string
+	Author string
+	Limit  int
+	Offset int
+}
+
+type UserQuery struct {
+	Query  Query
+	Limit  int
+	Offset int
+}
+
+// Hit is a matching document, the best first. Snippet is escaped HTML with
+// the matches in <mark> tags.
+type Hit struct {
+	ID      int64
+	Score   float64
+	Snippet string
+}
+
+type FacetTerm struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// Facets count the matches of a post search by tag and by author, over all
+// the matches and not only the page returned.
+type Facets struct {
+	Tags    []FacetTerm `json:"tags"`
+	Authors []FacetTerm `json:"authors"`
+}
+
+type PostResults struct {
+	Hits   []Hit
+	Total  int
+	Facets Facets
+}
+
+type UserResults struct {
+	Hits  []Hit
+	Total int
+}
+
+// Index is a search backend kept up to date from the writes of posts and
+// users. Indexing a document that is already there replaces it.
+type Index interface {
+	IndexPosts(ctx context.Context, docs ...PostDocument) error
+	DeletePosts(ctx context.Context, ids ...int64) error
+	IndexUsers(ctx context.Context, docs ...UserDocument) error
+	DeleteUsers(ctx context.Context, ids ...int64) error
+	SearchPosts(ctx context.Context, q PostQuery) (*PostResults, error)
+	SearchUsers(ctx context.Context, q UserQuery) (*UserResults, error)
+	Close() error
+}
@@ -0,0 +1,114 @@
+// Package search parses what users type in the search box.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// MaxTerms caps the terms of a query, the rest is ignored.
+const MaxTerms = 10
+
+var ErrEmptyQuery = errors.New("search query has no words to look for")
+
+// Term is a word, or a phrase when it has several words that must follow each
+// other. A prefix term matches the words starting with its last word and an
+// excluded term rules out what matches it.
+type Term struct {
+	Words   []string
+	Prefix  bool
+	Exclude bool
+}
+
+// Query is a parsed search, it matches what matches all of its terms.
+type Query struct {
+	Terms []Term
+}
+
+// Parse reads a query the way search boxes usually work: words are all
+// required, "quoted words" are a phrase, a trailing * makes a prefix and a
+// leading - excludes a term. Anything that is not a letter or a digit
+// separates words, so "e-mail" is the phrase "e mail".
+func Parse(q string) (Query, error) {
+	var query Query
+	positive := false
+
+	rest := strings.TrimSpace(q)
+	for rest != "" && len(query.Terms) < MaxTerms {
+		var (
+			term Term
+			raw  string
+		)
+
+		if strings.HasPrefix(rest, "-") {
+			term.Exclude = true
+			rest = rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				raw, rest = rest, ""
+			} else {
+				raw, rest = rest[:end], rest[end:]
+			}
+		}
+		rest = strings.TrimSpace(rest)
+
+		raw = strings.TrimSpace(raw)
+		term.Prefix = strings.HasSuffix(raw, "*")
+		term.Words = words(raw)
+		if len(term.Words) == 0 {
+			continue
+		}
+
+		positive = positive || !term.Exclude
+		query.Terms = append(query.Terms, term)
+	}
+
+	if !positive {
+		return Query{}, ErrEmptyQuery
+	}
+
+	return query, nil
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TSQuery writes the query in the syntax of Postgres to_tsquery. Words only
+// have letters and digits, so they can't inject operators.
+func (q Query) TSQuery() string {
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		words := make([]string, len(t.Words))
+		for j, w := range t.Words {
+			words[j] = "'" + w + "'"
+		}
+		if t.Prefix {
+			words[len(words)-1] += ":*"
+		}
+
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if t.Exclude {
+			term = "!" + term
+		}
+		terms[i] = term
+	}
+
+	return strings.Join(terms, " & ")
+}
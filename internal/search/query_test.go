@@ -0,0 +1,45 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{q: "go", want: "'go'"},
+		{q: "  Go   Redis ", want: "'go' & 'redis'"},
+		{q: `"full text" search`, want: "('full' <-> 'text') & 'search'"},
+		{q: "post*", want: "'post':*"},
+		{q: `"open sou*"`, want: "('open' <-> 'sou':*)"},
+		{q: "go -java", want: "'go' & !'java'"},
+		{q: "e-mail", want: "('e' <-> 'mail')"},
+		{q: `it's 'quoted' & | ! <-> :*`, want: "('it' <-> 's') & 'quoted'"},
+		{q: `"unclosed phrase`, want: "('unclosed' <-> 'phrase')"},
+		{q: "café niño", want: "'café' & 'niño'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			query, err := Parse(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := query.TSQuery(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("should reject queries without words to match", func(t *testing.T) {
+		for _, q := range []string{"", "   ", "&|!", "-java"} {
+			if _, err := Parse(q); !errors.Is(err, ErrEmptyQuery) {
+				t.Errorf("%q: got error %v, want %v", q, err, ErrEmptyQuery)
+			}
+		}
+	})
+}
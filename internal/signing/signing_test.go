@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner("secret")
+	payload := []byte{1, 0, 0, 0, 0, 0, 0, 0, 42, 'm', 'e'}
+
+	t.Run("should verify what it signed", func(t *testing.T) {
+		got, err := signer.Verify(signer.Sign(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, payload) {
+			t.Errorf("got %v, want %v", got, payload)
+		}
+	})
+
+	t.Run("should verify empty payloads", func(t *testing.T) {
+		got, err := signer.Verify(signer.Sign(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != 0 {
+			t.Errorf("got %v, want an empty payload", got)
+		}
+	})
+
+	t.Run("should reject tampered tokens", func(t *testing.T) {
+		token := []byte(signer.Sign(payload))
+		for i := range token {
+			tampered := bytes.Clone(token)
+			tampered[i] ^= 1
+
+			if _, err := signer.Verify(string(tampered)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("byte %d: got error %v, want %v", i, err, ErrInvalid)
+			}
+		}
+	})
+
+	t.Run("should reject tokens signed with another secret", func(t *testing.T) {
+		token := NewSigner("other").Sign(payload)
+
+		if _, err := signer.Verify(token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("got error %v, want %v", err, ErrInvalid)
+		}
+	})
+
+	t.Run("should reject garbage", func(t *testing.T) {
+		for _, token := range []string{"", "abc", "!!!!"} {
+			if _, err := signer.Verify(token); !errors.Is(err, ErrInvalid) {
+				t.Errorf("%q: got error %v, want %v", token, err, ErrInvalid)
+			}
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const MaxPostAttachments = 4
+
+var ErrInvalidAttachment = errors.New("attachments must be your own unused uploads")
+
+// Attachment is an image uploaded by a user, it stays orphaned until a post
+// references it.
+type Attachment struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	PostID       *int64    `json:"post_id,omitempty"`
+	AltText      string    `json:"alt_text"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Blurhash     string    `json:"blurhash"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type AttachmentStore struct {
+	db *sql.DB
+}
+
+func (s *AttachmentStore) Create(ctx context.Context, attachment *Attachment) error {
+	query := `INSERT INTO attachments (user_id, content_type, size, width, height, blurhash,
+			storage_key, thumbnail_key, url, thumbnail_url)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		attachment.UserID,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.Width,
+		attachment.Height,
+		attachment.Blurhash,
+		attachment.StorageKey,
+		attachment.ThumbnailKey,
+		attachment.URL,
+		attachment.ThumbnailURL,
+	).Scan(&attachment.ID, &attachment.CreatedAt)
+}
+
+// DeleteOrphaned deletes up to limit uploads created before that no post
+// references and returns them, so their blobs can be removed too. Rows taken
+// by another instance are skipped.
+func (s *AttachmentStore) DeleteOrphaned(ctx context.Context, before time.Time, limit int) ([]Attachment, error) {
+	query := `DELETE FROM attachments WHERE id IN (
+				SELECT id FROM attachments
+				WHERE post_id IS NULL AND created_at < $1
+				ORDER BY created_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			) RETURNING id, user_id, storage_key, thumbnail_key`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.UserID, &a.StorageKey, &a.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// CanView reports whether viewerID is allowed to see the blob under key, an
+// image or a thumbnail. Uploads are seen by their uploader until a post uses
+// them, then by whoever can see the post.
+func (s *AttachmentStore) CanView(ctx context.Context, viewerID int64, key string) (bool, error) {
+	query := `SELECT EXISTS (
+				SELECT 1 FROM attachments a
+				LEFT JOIN posts p ON p.id = a.post_id
+				WHERE (a.storage_key = $1 OR a.thumbnail_key = $1) AND (a.user_id = $2 OR
+				(p.id IS NOT NULL AND p.deleted_at IS NULL AND ` + visibleTo("$2") + `))
+			)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, key, viewerID).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
+
+// setPostAttachments claims the uploads of a new post, in the order given.
+// Each one has to belong to the author and not be used by another post yet.
+func setPostAttachments(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if len(post.Attachments) == 0 {
+		return nil
+	}
+
+	if len(post.Attachments) > MaxPostAttachments {
+		return ErrInvalidAttachment
+	}
+
+	query := `UPDATE attachments SET post_id = $1, position = $2, alt_text = $3
+			WHERE id = $4 AND user_id = $5 AND post_id IS NULL`
+
+	for i, attachment := range post.Attachments {
+		res, err := tx.ExecContext(ctx, query, post.ID, i, attachment.AltText, attachment.ID, post.UserID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrInvalidAttachment
+		}
+	}
+
+	return nil
+}
+
+// attachMedia loads the attachments of posts.
+func attachMedia(ctx context.Context, db *sql.DB, posts ...*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	byPost := make(map[int64][]Attachment, len(posts))
+	if len(ids) > 0 {
+		query := `SELECT id, user_id, post_id, alt_text, content_type, size, width, height, blurhash,
+				url, thumbnail_url, created_at
+				FROM attachments WHERE post_id = ANY($1)
+				ORDER BY post_id, position`
+
+		rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var a Attachment
+			err := rows.Scan(
+				&a.ID,
+				&a.UserID,
+				&a.PostID,
+				&a.AltText,
+				&a.ContentType,
+				&a.Size,
+				&a.Width,
+				&a.Height,
+				&a.Blurhash,
+				&a.URL,
+				&a.ThumbnailURL,
+				&a.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			byPost[*a.PostID] = append(byPost[*a.PostID], a)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, post := range posts {
+		post.Attachments = byPost[post.ID]
+		if post.Attachments == nil {
+			post.Attachments = []Attachment{}
+		}
+	}
+
+	return nil
+}
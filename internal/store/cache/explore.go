@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// exploreExp is short enough that recent posts don't look stale, explore is
+// the same for every visitor so a single read per minute serves all of them.
+const exploreExp = time.Minute
+
+type ExploreStore struct {
+	rdb *redis.Client
+}
+
+func (s *ExploreStore) Get(ctx context.Context, window time.Duration) (*store.Explore, error) {
+	cacheKey := fmt.Sprintf("explore-%v", window)
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+
+	if err == redis.Nil {
+		return nil, nil // Cache miss
+	} else if err != nil {
+		return nil, err // Redis error
+	}
+
+	var explore store.Explore
+	if err := json.Unmarshal([]byte(data), &explore); err != nil {
+		return nil, err
+	}
+	return &explore, nil
+}
+
+func (s *ExploreStore) Set(ctx context.Context, window time.Duration, explore *store.Explore) error {
+	cacheKey := fmt.Sprintf("explore-%v", window)
+
+	data, err := json.Marshal(explore)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, cacheKey, data, exploreExp).Err()
+}
+
+// MemoryExploreStore keeps explore in process for deployments without redis,
+// every instance then loads it once per exploreExp.
+type MemoryExploreStore struct {
+	mu      sync.Mutex
+	entries map[time.Duration]memoryExplore
+}
+
+type memoryExplore struct {
+	explore   *store.Explore
+	expiresAt time.Time
+}
+
+func (s *MemoryExploreStore) Get(ctx context.Context, window time.Duration) (*store.Explore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[window]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, nil // Cache miss
+	}
+	return entry.explore, nil
+}
+
+func (s *MemoryExploreStore) Set(ctx context.Context, window time.Duration, explore *store.Explore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[time.Duration]memoryExplore)
+	}
+	s.entries[window] = memoryExplore{explore: explore, expiresAt: time.Now().Add(exploreExp)}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+func TestMemoryExploreStore(t *testing.T) {
+	ctx := context.Background()
+	explore := &store.Explore{Recent: []store.PostWithMetadata{{Post: store.Post{ID: 1}}}}
+
+	t.Run("should miss before anything is set", func(t *testing.T) {
+		s := &MemoryExploreStore{}
+
+		got, err := s.Get(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil {
+			t.Errorf("got %+v, want a miss", got)
+		}
+	})
+
+	t.Run("should return what was set for the window", func(t *testing.T) {
+		s := &MemoryExploreStore{}
+		if err := s.Set(ctx, time.Hour, explore); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Get(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != explore {
+			t.Errorf("got %+v, want %+v", got, explore)
+		}
+
+		if got, _ := s.Get(ctx, 24*time.Hour); got != nil {
+			t.Errorf("got %+v for another window, want a miss", got)
+		}
+	})
+
+	t.Run("should miss once the entry expired", func(t *testing.T) {
+		s := &MemoryExploreStore{entries: map[time.Duration]memoryExplore{
+			time.Hour: {explore: explore, expiresAt: time.Now().Add(-time.Second)},
+		}}
+
+		got, err := s.Get(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil {
+			t.Errorf("got %+v, want a miss", got)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package cache
 
 import (
 	"context"
+	"time"
 
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 )
 
@@ -10,7 +12,12 @@ type MockCacheStore struct{}
 
 func NewMockCache() Storage {
 	return Storage{
-		Users: &MockCacheStore{},
+		Users:     &MockCacheStore{},
+		Tags:      &MockTagCacheStore{},
+		Timelines: &MockTimelineCacheStore{},
+		Explore:   &MockExploreCacheStore{},
+
+		Notifications: &MockNotificationCacheStore{},
 	}
 }
 
@@ -25,3 +32,63 @@ func (m *MockCacheStore) Set(ctx context.Context, user *store.User) error {
 func (m *MockCacheStore) Delete(ctx context.Context, userID int64) error {
 	return nil
 }
+
+type MockTagCacheStore struct{}
+
+func (m *MockTagCacheStore) GetTrending(ctx context.Context, window time.Duration) ([]store.TrendingTag, error) {
+	return nil, nil
+}
+
+func (m *MockTagCacheStore) SetTrending(ctx context.Context, window time.Duration, tags []store.TrendingTag) error {
+	return nil
+}
+
+type MockTimelineCacheStore struct{}
+
+func (m *MockTimelineCacheStore) Ready(ctx context.Context, userID int64) (bool, error) {
+	return false, nil
+}
+
+func (m *MockTimelineCacheStore) Build(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error {
+	return nil
+}
+
+func (m *MockTimelineCacheStore) Push(ctx context.Context, entry store.TimelineEntry, userIDs []int64, capacity int) error {
+	return nil
+}
+
+func (m *MockTimelineCacheStore) Add(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error {
+	return nil
+}
+
+func (m *MockTimelineCacheStore) Remove(ctx context.Context, userID int64, postIDs []int64) error {
+	return nil
+}
+
+func (m *MockTimelineCacheStore) Get(ctx context.Context, userID int64, c *cursor.Cursor, limit int) ([]store.TimelineEntry, error) {
+	return nil, nil
+}
+
+type MockExploreCacheStore struct{}
+
+func (m *MockExploreCacheStore) Get(ctx context.Context, window time.Duration) (*store.Explore, error) {
+	return nil, nil
+}
+
+func (m *MockExploreCacheStore) Set(ctx context.Context, window time.Duration, explore *store.Explore) error {
+	return nil
+}
+
+type MockNotificationCacheStore struct{}
+
+func (m *MockNotificationCacheStore) GetUnread(ctx context.Context, userID int64) (int, bool, error) {
+	return 0, false, nil
+}
+
+func (m *MockNotificationCacheStore) SetUnread(ctx context.Context, userID int64, count int) error {
+	return nil
+}
+
+func (m *MockNotificationCacheStore) DeleteUnread(ctx context.Context, userID int64) error {
+	return nil
+}
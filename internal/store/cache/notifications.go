@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreadExp bounds how long a count can be wrong if an invalidation is lost.
+const unreadExp = time.Minute * 10
+
+type NotificationStore struct {
+	rdb *redis.Client
+}
+
+// GetUnread returns the cached unread count of a user, ok is false on a miss.
+func (s *NotificationStore) GetUnread(ctx context.Context, userID int64) (count int, ok bool, err error) {
+	count, err = s.rdb.Get(ctx, unreadKey(userID)).Int()
+	if err == redis.Nil {
+		return 0, false, nil // Cache miss
+	} else if err != nil {
+		return 0, false, err // Redis error
+	}
+
+	return count, true, nil
+}
+
+func (s *NotificationStore) SetUnread(ctx context.Context, userID int64, count int) error {
+	return s.rdb.SetEx(ctx, unreadKey(userID), count, unreadExp).Err()
+}
+
+// DeleteUnread drops the count when notifications are added or read, the
+// next read counts them again.
+func (s *NotificationStore) DeleteUnread(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, unreadKey(userID)).Err()
+}
+
+func unreadKey(userID int64) string {
+	return fmt.Sprintf("unread-notifications-%v", userID)
+}
@@ -2,8 +2,10 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/redis/go-redis/v9"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
 	"ontopsolutions.net/gasperlf/social/internal/store"
 )
 
@@ -13,10 +15,36 @@ type Storage struct {
 		Set(ctx context.Context, user *store.User) error
 		Delete(ctx context.Context, userID int64) error
 	}
+	Tags interface {
+		GetTrending(ctx context.Context, window time.Duration) ([]store.TrendingTag, error)
+		SetTrending(ctx context.Context, window time.Duration, tags []store.TrendingTag) error
+	}
+	Timelines interface {
+		Ready(ctx context.Context, userID int64) (bool, error)
+		Build(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error
+		Push(ctx context.Context, entry store.TimelineEntry, userIDs []int64, capacity int) error
+		Add(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error
+		Remove(ctx context.Context, userID int64, postIDs []int64) error
+		Get(ctx context.Context, userID int64, c *cursor.Cursor, limit int) ([]store.TimelineEntry, error)
+	}
+	Explore interface {
+		Get(ctx context.Context, window time.Duration) (*store.Explore, error)
+		Set(ctx context.Context, window time.Duration, explore *store.Explore) error
+	}
+	Notifications interface {
+		GetUnread(ctx context.Context, userID int64) (int, bool, error)
+		SetUnread(ctx context.Context, userID int64, count int) error
+		DeleteUnread(ctx context.Context, userID int64) error
+	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:     &UserStore{rdb: rdb},
+		Tags:      &TagStore{rdb: rdb},
+		Timelines: &TimelineStore{rdb: rdb},
+		Explore:   &ExploreStore{rdb: rdb},
+
+		Notifications: &NotificationStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+const trendingTagsExp = time.Minute
+
+type TagStore struct {
+	rdb *redis.Client
+}
+
+func (s *TagStore) GetTrending(ctx context.Context, window time.Duration) ([]store.TrendingTag, error) {
+	cacheKey := fmt.Sprintf("trending-tags-%v", window)
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+
+	if err == redis.Nil {
+		return nil, nil // Cache miss
+	} else if err != nil {
+		return nil, err // Redis error
+	}
+
+	var tags []store.TrendingTag
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *TagStore) SetTrending(ctx context.Context, window time.Duration, tags []store.TrendingTag) error {
+	cacheKey := fmt.Sprintf("trending-tags-%v", window)
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, cacheKey, data, trendingTagsExp).Err()
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+	"ontopsolutions.net/gasperlf/social/internal/store"
+)
+
+// timelineExp drops the timelines of users who stopped reading them, they are
+// rebuilt on their next visit.
+const timelineExp = time.Hour * 24 * 7
+
+// fanOutBatch is how many timelines are written per round trip.
+const fanOutBatch = 1000
+
+// addEntries adds posts to a timeline that has been built and trims it to its
+// capacity. Timelines that don't exist are left alone, a partial timeline
+// would pass for a complete one.
+//
+// KEYS: timeline, ready marker. ARGV: capacity, then score and post ID pairs.
+var addEntries = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('ZADD', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -(tonumber(ARGV[1]) + 1))
+redis.call('EXPIRE', KEYS[1], redis.call('TTL', KEYS[2]))
+return 1
+`)
+
+// TimelineStore keeps the home timeline of each user as a capped sorted set
+// of post IDs scored by creation time.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%v", userID)
+}
+
+// timelineReadyKey marks a timeline as built, an empty one has no sorted set.
+func timelineReadyKey(userID int64) string {
+	return fmt.Sprintf("timeline-ready-%v", userID)
+}
+
+func score(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+func (s *TimelineStore) Ready(ctx context.Context, userID int64) (bool, error) {
+	n, err := s.rdb.Exists(ctx, timelineReadyKey(userID)).Result()
+	return n == 1, err
+}
+
+// Build replaces the timeline of a user.
+func (s *TimelineStore) Build(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error {
+	if len(entries) > capacity {
+		entries = entries[:capacity]
+	}
+
+	key := timelineKey(userID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(entries) > 0 {
+			members := make([]redis.Z, len(entries))
+			for i, e := range entries {
+				members[i] = redis.Z{Score: score(e.CreatedAt), Member: e.PostID}
+			}
+			pipe.ZAdd(ctx, key, members...)
+			pipe.Expire(ctx, key, timelineExp)
+		}
+		pipe.Set(ctx, timelineReadyKey(userID), 1, timelineExp)
+		return nil
+	})
+	return err
+}
+
+// Push adds a post to the built timelines of users.
+func (s *TimelineStore) Push(ctx context.Context, entry store.TimelineEntry, userIDs []int64, capacity int) error {
+	if err := addEntries.Load(ctx, s.rdb).Err(); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(userIDs); start += fanOutBatch {
+		end := min(start+fanOutBatch, len(userIDs))
+
+		_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, userID := range userIDs[start:end] {
+				addEntries.EvalSha(ctx, pipe,
+					[]string{timelineKey(userID), timelineReadyKey(userID)},
+					capacity, score(entry.CreatedAt), entry.PostID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add backfills posts into the timeline of a user, when it is built.
+func (s *TimelineStore) Add(ctx context.Context, userID int64, entries []store.TimelineEntry, capacity int) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 1+2*len(entries))
+	args = append(args, capacity)
+	for _, e := range entries {
+		args = append(args, score(e.CreatedAt), e.PostID)
+	}
+
+	return addEntries.Run(ctx, s.rdb, []string{timelineKey(userID), timelineReadyKey(userID)}, args...).Err()
+}
+
+// Remove prunes posts from the timeline of a user.
+func (s *TimelineStore) Remove(ctx context.Context, userID int64, postIDs []int64) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id
+	}
+
+	return s.rdb.ZRem(ctx, timelineKey(userID), members...).Err()
+}
+
+// Get returns up to limit entries of the timeline of a user, newest first,
+// before the position of c when it is set. Reading a timeline keeps it alive.
+func (s *TimelineStore) Get(ctx context.Context, userID int64, c *cursor.Cursor, limit int) ([]store.TimelineEntry, error) {
+	maxScore := "+inf"
+	if c != nil {
+		maxScore = strconv.FormatFloat(score(c.CreatedAt), 'f', -1, 64)
+	}
+
+	// posts created at the same time as the cursor may come before or after
+	// it, read a few more and sort it out below
+	members, err := s.rdb.ZRevRangeByScoreWithScores(ctx, timelineKey(userID), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   maxScore,
+		Count: int64(limit + 20),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	s.rdb.Expire(ctx, timelineKey(userID), timelineExp)
+	s.rdb.Expire(ctx, timelineReadyKey(userID), timelineExp)
+
+	entries := make([]store.TimelineEntry, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(fmt.Sprint(m.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		createdAt := time.UnixMicro(int64(math.Round(m.Score)))
+		if c != nil {
+			before := createdAt.Before(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id < c.ID)
+			if !before {
+				continue
+			}
+		}
+
+		entries = append(entries, store.TimelineEntry{PostID: id, CreatedAt: createdAt})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].PostID > entries[j].PostID
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
@@ -3,73 +3,230 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"time"
 )
 
+// MaxCommentDepth is the deepest level a reply can be nested at, top level
+// comments have depth 0.
+const MaxCommentDepth = 5
+
+const deletedCommentContent = "[deleted]"
+
+var ErrMaxCommentDepth = errors.New("comment thread is too deep")
+
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID          int64      `json:"id"`
+	PostID      int64      `json:"post_id"`
+	ParentID    *int64     `json:"parent_id"`
+	Depth       int        `json:"depth"`
+	UserID      int64      `json:"user_id"`
+	Content     string     `json:"content"`
+	ContentHTML string     `json:"content_html"`
+	CreatedAt   time.Time  `json:"created_at"`
+	EditedAt    *time.Time `json:"edited_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	ReplyCount  int        `json:"reply_count"`
+	Mentions    []Mention  `json:"mentions"`
+	User        User       `json:"user"`
 }
+
 type CommentStore struct {
 	db *sql.DB
 }
 
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content)
-		  VALUES ($1, $2, $3) returning id, created_at`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content).
-		Scan(&comment.ID, &comment.CreatedAt)
+
+	comment.Depth = 0
+	if comment.ParentID != nil {
+		parentDepth, err := s.parentDepth(ctx, comment.PostID, *comment.ParentID)
+		if err != nil {
+			return err
+		}
+
+		if parentDepth+1 > MaxCommentDepth {
+			return ErrMaxCommentDepth
+		}
+		comment.Depth = parentDepth + 1
+	}
+
+	query := `INSERT INTO comments (post_id, parent_id, depth, user_id, content, content_html, language)
+		  VALUES ($1, $2, $3, $4, $5, $6, (SELECT language FROM posts WHERE id = $1)) returning id, created_at`
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		html, err := renderContent(ctx, tx, comment.Content)
+		if err != nil {
+			return err
+		}
+		comment.ContentHTML = html
+
+		err = tx.QueryRowContext(ctx, query, comment.PostID, comment.ParentID, comment.Depth, comment.UserID, comment.Content, comment.ContentHTML).
+			Scan(&comment.ID, &comment.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		return setCommentMentions(ctx, tx, comment)
+	})
 
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return attachCommentMentions(ctx, s.db, comment)
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, id int64) ([]Comment, error) {
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	query := `SELECT c.id, c.post_id, c.parent_id, c.depth, c.user_id, c.content, COALESCE(c.content_html, ''), c.created_at, c.edited_at, c.deleted_at,
+			  (SELECT count(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+			  u.username, u.id
+			  FROM comments c JOIN users u on c.user_id = u.id
+			  WHERE c.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id
+	comment, err := scanComment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := attachCommentMentions(ctx, s.db, comment); err != nil {
+		return nil, err
+	}
+
+	renderMissingComments(comment)
+
+	return comment, nil
+}
+
+// GetByPostID returns a page of comments for a post. Without a parent it
+// lists the top level comments, otherwise the direct replies of the parent.
+// It reports whether more comments follow in the direction it was read.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID int64, cq CommentPaginationQuery) ([]Comment, bool, error) {
+	cond, order := keyset("c", cq.Cursor, false, "$5", "$6")
+
+	query := `SELECT c.id, c.post_id, c.parent_id, c.depth, c.user_id, c.content, COALESCE(c.content_html, ''), c.created_at, c.edited_at, c.deleted_at,
+			  (SELECT count(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+			  u.username, u.id
 		 	  FROM comments c JOIN users u on c.user_id = u.id
 			  WHERE c.post_id = $1
-			  ORDER BY c.created_at DESC`
+			  AND COALESCE(c.parent_id, 0) = $2
+			  AND ($3 = 0 OR (c.created_at, c.id) > (SELECT a.created_at, a.id FROM comments a WHERE a.id = $3))
+			  AND ` + cond + `
+			  ORDER BY c.created_at ` + order + `, c.id ` + order + `
+			  LIMIT $4`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	rows, err := s.db.QueryContext(ctx, query, id)
+	args := []any{postID, cq.ParentID, cq.After, cq.Limit + 1}
+	if cq.Cursor != nil {
+		args = append(args, cq.Cursor.CreatedAt, cq.Cursor.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
 	comments := []Comment{}
 
 	for rows.Next() {
-		var comment Comment
-		comment.User = User{}
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.User.Username,
-			&comment.User.ID,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		comments = append(comments, comment)
+		comments = append(comments, *comment)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	comments, more := trimPage(comments, cq.Limit, cq.Cursor)
+
+	page := make([]*Comment, len(comments))
+	for i := range comments {
+		page[i] = &comments[i]
+	}
+
+	if err := attachCommentMentions(ctx, s.db, page...); err != nil {
+		return nil, false, err
 	}
-	return comments, nil
+
+	renderMissingComments(page...)
+
+	return comments, more, nil
+}
+
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `UPDATE comments SET content = $1, content_html = $3, edited_at = NOW()
+			  WHERE id = $2 AND deleted_at IS NULL
+			  RETURNING edited_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		html, err := renderContent(ctx, tx, comment.Content)
+		if err != nil {
+			return err
+		}
+		comment.ContentHTML = html
+
+		err = tx.QueryRowContext(ctx, query, comment.Content, comment.ID, comment.ContentHTML).Scan(&comment.EditedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		return setCommentMentions(ctx, tx, comment)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return attachCommentMentions(ctx, s.db, comment)
+}
+
+// Delete soft deletes a comment so replies keep their place in the thread.
+// deletedBy is the author or the moderator that removed it.
+func (s *CommentStore) Delete(ctx context.Context, commentID int64, deletedBy int64) error {
+	query := `UPDATE comments SET deleted_at = NOW(), deleted_by = $2
+			  WHERE id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, commentID, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
 }
 
 func (s *CommentStore) DeleteByPostID(ctx context.Context, postID int64) error {
@@ -93,3 +250,53 @@ func (s *CommentStore) DeleteByPostID(ctx context.Context, postID int64) error {
 
 	return nil
 }
+
+func (s *CommentStore) parentDepth(ctx context.Context, postID, parentID int64) (int, error) {
+	query := `SELECT depth FROM comments WHERE id = $1 AND post_id = $2`
+
+	var depth int
+	err := s.db.QueryRowContext(ctx, query, parentID, postID).Scan(&depth)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrorNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return depth, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanComment(row rowScanner) (*Comment, error) {
+	comment := &Comment{}
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.ParentID,
+		&comment.Depth,
+		&comment.UserID,
+		&comment.Content,
+		&comment.ContentHTML,
+		&comment.CreatedAt,
+		&comment.EditedAt,
+		&comment.DeletedAt,
+		&comment.ReplyCount,
+		&comment.User.Username,
+		&comment.User.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.DeletedAt != nil {
+		comment.Content = deletedCommentContent
+		comment.ContentHTML = ""
+	}
+
+	return comment, nil
+}
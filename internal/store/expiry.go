@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Extend pushes back the expiry of an ephemeral post of userID. It only
+// applies to posts that have not expired yet and only moves the expiry later.
+func (s *PostStore) Extend(ctx context.Context, postID, userID int64, expiresAt time.Time) error {
+	query := `UPDATE posts SET expires_at = $3
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+			AND expires_at > NOW() AND expires_at < $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, postID, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// PurgeExpired permanently removes up to limit expired posts along with their
+// comments and attachments. It returns the IDs of the posts and the
+// attachments, whose blobs are for the caller to delete. Rows taken by
+// another instance are skipped.
+func (s *PostStore) PurgeExpired(ctx context.Context, limit int) ([]int64, []Attachment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		ids         []int64
+		attachments []Attachment
+	)
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT id FROM posts
+				WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+				ORDER BY expires_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED`
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		query = `DELETE FROM attachments WHERE post_id = ANY($1)
+				RETURNING id, user_id, storage_key, thumbnail_key`
+
+		arows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer arows.Close()
+
+		for arows.Next() {
+			var a Attachment
+			if err := arows.Scan(&a.ID, &a.UserID, &a.StorageKey, &a.ThumbnailKey); err != nil {
+				return err
+			}
+			attachments = append(attachments, a)
+		}
+
+		if err := arows.Err(); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM comments WHERE post_id = ANY($1)`
+		if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM posts WHERE id = ANY($1)`
+		_, err = tx.ExecContext(ctx, query, pq.Array(ids))
+		return err
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ids, attachments, nil
+}
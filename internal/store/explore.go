@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// SuggestedUser is a user worth following found on explore, without anything
+// private about them.
+type SuggestedUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Followers int    `json:"followers"`
+	Posts     int    `json:"posts"`
+}
+
+// Explore is what anonymous visitors are shown to discover content outside
+// of the follow graph.
+type Explore struct {
+	Recent         []PostWithMetadata `json:"recent"`
+	Popular        []PostWithMetadata `json:"popular"`
+	SuggestedUsers []SuggestedUser    `json:"suggested_users"`
+}
+
+// exploreColumns are the columns of the explore listings, the ones scanPosts
+// reads.
+const exploreColumns = `
+	select p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status,
+	p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.pinned_at,
+	p.expires_at, u.username,
+	(select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL) as comments_count
+	from posts p join users u on u.id = p.user_id`
+
+// GetRecentPublic returns the newest posts anybody can see.
+func (s *PostStore) GetRecentPublic(ctx context.Context, limit int) ([]PostWithMetadata, error) {
+	query := exploreColumns + `
+	where p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$1") + `
+	order by p.created_at desc, p.id desc
+	LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	posts, err := s.scanPosts(ctx, query, 0, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, s.attachPublic(ctx, posts)
+}
+
+// GetPopular returns the posts anybody can see created within window that got
+// the most engagement. A comment weighs two poll votes and ten views weigh a
+// poll vote.
+func (s *PostStore) GetPopular(ctx context.Context, window time.Duration, limit int) ([]PostWithMetadata, error) {
+	query := exploreColumns + `
+	where p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$1") + `
+	AND p.created_at > NOW() - make_interval(secs => $2)
+	order by 2 * (select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL)
+	+ (select count(distinct v.user_id) from poll_votes v join polls pl on pl.id = v.poll_id where pl.post_id = p.id)
+	+ coalesce((select sum(pv.views) from post_views pv where pv.post_id = p.id), 0) / 10.0 desc,
+	p.created_at desc, p.id desc
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	posts, err := s.scanPosts(ctx, query, 0, window.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, s.attachPublic(ctx, posts)
+}
+
+// attachPublic attaches what a listing shows to posts read for an anonymous
+// viewer.
+func (s *PostStore) attachPublic(ctx context.Context, posts []PostWithMetadata) error {
+	if err := attachPostMentions(ctx, s.db, feedPosts(posts)...); err != nil {
+		return err
+	}
+
+	renderMissingPosts(feedPosts(posts)...)
+
+	if err := attachMedia(ctx, s.db, feedPosts(posts)...); err != nil {
+		return err
+	}
+
+	if err := attachPreviews(ctx, s.db, feedPosts(posts)...); err != nil {
+		return err
+	}
+
+	if err := attachPolls(ctx, s.db, 0, feedPosts(posts)...); err != nil {
+		return err
+	}
+
+	return attachDisplay(ctx, s.db, 0, feedPosts(posts)...)
+}
+
+// GetSuggested returns the active users who published public posts within
+// window, the most followed first.
+func (s *UserStore) GetSuggested(ctx context.Context, window time.Duration, limit int) ([]SuggestedUser, error) {
+	query := `
+	select u.id, u.username,
+	(select count(*) from followers f where f.user_id = u.id) as followers,
+	count(p.id) as posts
+	from users u join posts p on p.user_id = u.id
+	where u.is_active AND p.status = 'published' AND p.deleted_at IS NULL AND p.visibility = 'public'
+	AND (p.expires_at IS NULL OR p.expires_at > NOW())
+	AND p.created_at > NOW() - make_interval(secs => $1)
+	group by u.id, u.username
+	order by followers desc, posts desc, u.id
+	LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []SuggestedUser{}
+	for rows.Next() {
+		var u SuggestedUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.Followers, &u.Posts); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
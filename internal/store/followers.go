@@ -23,28 +23,128 @@ func (s *FollowerStore) Follow(ctx context.Context, followedID int64, userID int
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followedID)
-
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrorConflict
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, userID, followedID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrorConflict
+			}
+			return err
 		}
-	}
-	return nil
+
+		return updateFollowersCount(ctx, tx, userID, 1)
+	})
 }
 
 func (s *FollowerStore) Unfollow(ctx context.Context, followedID int64, userID int64) error {
-	query := `DELETE FROM followers (user_id, follower_id)
-	          WHERE user_id=$1 
+	query := `DELETE FROM followers
+	          WHERE user_id=$1
 			  AND follower_id=$2
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followedID)
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query, userID, followedID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		return updateFollowersCount(ctx, tx, userID, -1)
+	})
+}
+
+// updateFollowersCount keeps users.followers_count in step with the followers
+// table, it is read on every home timeline.
+func updateFollowersCount(ctx context.Context, tx *sql.Tx, userID int64, delta int) error {
+	query := `UPDATE users SET followers_count = followers_count + $2 WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, userID, delta)
+	return err
+}
+
+// Followee is a user followed by someone, with their own follower count.
+type Followee struct {
+	UserID    int64
+	Followers int
+}
+
+// GetFollowing lists the users followed by userID.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID int64) ([]Followee, error) {
+	query := `SELECT f.user_id, u.followers_count
+			FROM followers f JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	following := []Followee{}
+	for rows.Next() {
+		var f Followee
+		if err := rows.Scan(&f.UserID, &f.Followers); err != nil {
+			return nil, err
+		}
+		following = append(following, f)
+	}
+
+	return following, rows.Err()
+}
+
+// IsMutual reports whether userID and otherID follow each other.
+func (s *FollowerStore) IsMutual(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT count(*) = 2 FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var mutual bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&mutual)
+	return mutual, err
+}
+
+func (s *FollowerStore) CountFollowers(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT followers_count FROM users WHERE id = $1`
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+)
+
+// Mention is a resolved @username in a post or comment, Start and End are
+// rune offsets into the content.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// ClaimPostMentions marks the mentions of a post as notified and returns the
+// mentioned users that had not been notified yet. Users who can't see the post
+// are left unclaimed, they are notified if an edit lets them see it.
+func (s *MentionStore) ClaimPostMentions(ctx context.Context, postID int64) ([]User, error) {
+	query := `UPDATE post_mentions m SET notified_at = NOW()
+			FROM users u, posts p
+			WHERE u.id = m.user_id AND p.id = m.post_id AND m.post_id = $1 AND m.active AND m.notified_at IS NULL
+			AND ` + visibleTo("m.user_id") + `
+			RETURNING u.id, u.username, u.email`
+
+	return s.claim(ctx, query, postID)
+}
+
+// ClaimCommentMentions is ClaimPostMentions for the mentions of a comment.
+func (s *MentionStore) ClaimCommentMentions(ctx context.Context, commentID int64) ([]User, error) {
+	query := `UPDATE comment_mentions m SET notified_at = NOW()
+			FROM users u, comments c, posts p
+			WHERE u.id = m.user_id AND c.id = m.comment_id AND p.id = c.post_id AND m.comment_id = $1 AND m.active
+			AND m.notified_at IS NULL AND ` + visibleTo("m.user_id") + `
+			RETURNING u.id, u.username, u.email`
+
+	return s.claim(ctx, query, commentID)
+}
+
+func (s *MentionStore) claim(ctx context.Context, query string, id int64) ([]User, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// setPostMentions stores the users mentioned in the post content, they are
+// the audience of mentioned-only posts.
+func setPostMentions(ctx context.Context, tx *sql.Tx, post *Post) error {
+	return setMentions(ctx, tx, "post_mentions", "post_id", post.ID, post.UserID, post.Content)
+}
+
+func setCommentMentions(ctx context.Context, tx *sql.Tx, comment *Comment) error {
+	return setMentions(ctx, tx, "comment_mentions", "comment_id", comment.ID, comment.UserID, comment.Content)
+}
+
+// setMentions syncs the mentions table with the usernames found in content.
+// Mentions no longer in the content are deactivated rather than deleted, so
+// their notification state survives edits.
+func setMentions(ctx context.Context, tx *sql.Tx, table, column string, id, authorID int64, content string) error {
+	usernames := pq.Array(entities.MentionedUsernames(content))
+
+	query := `UPDATE ` + table + ` SET active = user_id IN (SELECT id FROM users WHERE username = ANY($2))
+			WHERE ` + column + ` = $1`
+	if _, err := tx.ExecContext(ctx, query, id, usernames); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO ` + table + ` (` + column + `, user_id)
+			SELECT $1, id FROM users WHERE username = ANY($2) AND id <> $3
+			ON CONFLICT DO NOTHING`
+	_, err := tx.ExecContext(ctx, query, id, usernames, authorID)
+	return err
+}
+
+// mentionedUsers returns, for each of ids, the active mentions of the table
+// as a username to user ID map.
+func mentionedUsers(ctx context.Context, db *sql.DB, table, column string, ids []int64) (map[int64]map[string]int64, error) {
+	mentioned := make(map[int64]map[string]int64, len(ids))
+	if len(ids) == 0 {
+		return mentioned, nil
+	}
+
+	query := `SELECT m.` + column + `, u.id, u.username
+			FROM ` + table + ` m JOIN users u ON u.id = m.user_id
+			WHERE m.` + column + ` = ANY($1) AND m.active`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int64
+			userID   int64
+			username string
+		)
+		if err := rows.Scan(&id, &userID, &username); err != nil {
+			return nil, err
+		}
+
+		if mentioned[id] == nil {
+			mentioned[id] = make(map[string]int64)
+		}
+		mentioned[id][username] = userID
+	}
+
+	return mentioned, rows.Err()
+}
+
+// mentionEntities locates the resolved mentions in content. Mentions of
+// unknown users are left out.
+func mentionEntities(content string, users map[string]int64) []Mention {
+	mentions := []Mention{}
+	for _, e := range entities.Mentions(content) {
+		userID, ok := users[e.Text]
+		if !ok {
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			UserID:   userID,
+			Username: e.Text,
+			Start:    e.Start,
+			End:      e.End,
+		})
+	}
+	return mentions
+}
+
+func attachPostMentions(ctx context.Context, db *sql.DB, posts ...*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	mentioned, err := mentionedUsers(ctx, db, "post_mentions", "post_id", ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Mentions = mentionEntities(post.Content, mentioned[post.ID])
+	}
+
+	return nil
+}
+
+func attachCommentMentions(ctx context.Context, db *sql.DB, comments ...*Comment) error {
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	mentioned, err := mentionedUsers(ctx, db, "comment_mentions", "comment_id", ids)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range comments {
+		if comment.DeletedAt != nil {
+			comment.Mentions = []Mention{}
+		} else {
+			comment.Mentions = mentionEntities(comment.Content, mentioned[comment.ID])
+		}
+	}
+
+	return nil
+}
@@ -3,14 +3,22 @@ package store
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/search"
 )
 
 type MockUserStore struct{}
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Followers: &MockFollowerStore{Mutual: true},
+
+		Attachments: &MockAttachmentStore{},
+
+		NotificationSettings: &MockNotificationSettingStore{},
 	}
 }
 
@@ -19,15 +27,15 @@ func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, user *User) erro
 }
 
 func (m *MockUserStore) GetByID(ctx context.Context, id int64) (*User, error) {
-	return nil, nil
+	return &User{ID: id}, nil
 }
 
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return nil
 }
 
-func (m *MockUserStore) Activate(ctx context.Context, token string) error {
-	return nil
+func (m *MockUserStore) Activate(ctx context.Context, token string) (*User, error) {
+	return &User{}, nil
 }
 
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
@@ -37,3 +45,108 @@ func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
 func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	return nil, nil
 }
+
+func (m *MockUserStore) GetSuggested(ctx context.Context, window time.Duration, limit int) ([]SuggestedUser, error) {
+	return []SuggestedUser{}, nil
+}
+
+func (m *MockUserStore) GetSearchDocuments(ctx context.Context, ids []int64) ([]search.UserDocument, error) {
+	return []search.UserDocument{}, nil
+}
+
+func (m *MockUserStore) ListSearchDocuments(ctx context.Context, afterID int64, limit int) ([]search.UserDocument, error) {
+	return []search.UserDocument{}, nil
+}
+
+// MockFollowerStore follows nobody, Mutual is what IsMutual reports.
+type MockFollowerStore struct {
+	Mutual bool
+}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, followedID int64, userID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) Unfollow(ctx context.Context, followedID int64, userID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) GetFollowing(ctx context.Context, userID int64) ([]Followee, error) {
+	return []Followee{}, nil
+}
+
+func (m *MockFollowerStore) IsMutual(ctx context.Context, userID, otherID int64) (bool, error) {
+	return m.Mutual, nil
+}
+
+func (m *MockFollowerStore) CountFollowers(ctx context.Context, userID int64) (int, error) {
+	return 0, nil
+}
+
+func (m *MockFollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+// MockAttachmentStore knows no attachments, so no media is ever visible.
+type MockAttachmentStore struct{}
+
+func (m *MockAttachmentStore) Create(ctx context.Context, attachment *Attachment) error {
+	return nil
+}
+
+func (m *MockAttachmentStore) DeleteOrphaned(ctx context.Context, before time.Time, limit int) ([]Attachment, error) {
+	return []Attachment{}, nil
+}
+
+func (m *MockAttachmentStore) CanView(ctx context.Context, viewerID int64, key string) (bool, error) {
+	return false, nil
+}
+
+// MockNotificationSettingStore keeps the settings of every user in memory,
+// users start with the defaults.
+type MockNotificationSettingStore struct {
+	mu       sync.Mutex
+	settings map[int64]NotificationSettings
+}
+
+func (m *MockNotificationSettingStore) Get(ctx context.Context, userID int64) (*NotificationSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settings, ok := m.settings[userID]
+	if !ok {
+		settings = NotificationSettings{UserID: userID, Timezone: "UTC", Digest: DigestOff}
+	}
+
+	deliveries := make(map[string]string, len(defaultDeliveries))
+	for typ := range defaultDeliveries {
+		deliveries[typ] = settings.Delivery(typ)
+	}
+	settings.Deliveries = deliveries
+
+	return &settings, nil
+}
+
+func (m *MockNotificationSettingStore) Update(ctx context.Context, settings *NotificationSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.settings == nil {
+		m.settings = make(map[int64]NotificationSettings)
+	}
+	settings.UpdatedAt = time.Now()
+	m.settings[settings.UserID] = *settings
+	return nil
+}
+
+func (m *MockNotificationSettingStore) DueDigests(ctx context.Context, now time.Time, afterID int64, limit int) ([]DigestRecipient, error) {
+	return []DigestRecipient{}, nil
+}
+
+func (m *MockNotificationSettingStore) ClaimDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) (bool, error) {
+	return false, nil
+}
+
+func (m *MockNotificationSettingStore) ReleaseDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, claimedAt time.Time) error {
+	return nil
+}
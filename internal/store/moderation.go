@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const ModerationForceContentWarning = "force_content_warning"
+
+type ModerationStore struct {
+	db *sql.DB
+}
+
+// ForceContentWarning puts a content warning on a post that its author can't
+// remove, and records the action in the moderation log.
+func (s *ModerationStore) ForceContentWarning(ctx context.Context, postID, moderatorID int64, warning, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var previous string
+		query := `UPDATE posts p SET content_warning = $2, content_warning_forced = TRUE
+				FROM (SELECT id, content_warning FROM posts WHERE id = $1 FOR UPDATE) old
+				WHERE p.id = old.id AND p.deleted_at IS NULL
+				RETURNING old.content_warning`
+
+		err := tx.QueryRowContext(ctx, query, postID, warning).Scan(&previous)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `INSERT INTO moderation_log (moderator_id, action, post_id, details, reason)
+				VALUES ($1, $2, $3, $4, $5)`
+
+		details := fmt.Sprintf("content warning %q, was %q", warning, previous)
+		_, err = tx.ExecContext(ctx, query, moderatorID, ModerationForceContentWarning, postID, details, reason)
+		return err
+	})
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// How a type of notification reaches a user.
+const (
+	DeliveryInApp = "in_app"
+	DeliveryEmail = "email"
+	DeliveryAll   = "all"
+	DeliveryNone  = "none"
+)
+
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// defaultDeliveries keep mentions emailed, like they were before users could
+// choose.
+var defaultDeliveries = map[string]string{
+	NotificationFollow:        DeliveryInApp,
+	NotificationFollowRequest: DeliveryInApp,
+	NotificationComment:       DeliveryInApp,
+	NotificationReply:         DeliveryInApp,
+	NotificationMention:       DeliveryAll,
+	NotificationReaction:      DeliveryInApp,
+}
+
+// NotificationSettings are how a user wants to hear about what happens to
+// them. Quiet hours, in their timezone, hold back emails until they are over
+// and skip live pushes, notifications still reach the inbox and the digest.
+type NotificationSettings struct {
+	UserID int64 `json:"-"`
+	// Deliveries has the delivery of every notification type
+	Deliveries map[string]string `json:"deliveries"`
+	QuietStart string            `json:"quiet_start"`
+	QuietEnd   string            `json:"quiet_end"`
+	Timezone   string            `json:"timezone"`
+	Digest     string            `json:"digest"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+
+	LastDigestAt *time.Time `json:"-"`
+}
+
+// IsNotificationType reports whether typ is a known notification type.
+func IsNotificationType(typ string) bool {
+	_, ok := defaultDeliveries[typ]
+	return ok
+}
+
+// Delivery is how notifications of typ reach the user.
+func (s *NotificationSettings) Delivery(typ string) string {
+	if d, ok := s.Deliveries[typ]; ok {
+		return d
+	}
+	return defaultDeliveries[typ]
+}
+
+func (s *NotificationSettings) InApp(typ string) bool {
+	d := s.Delivery(typ)
+	return d == DeliveryInApp || d == DeliveryAll
+}
+
+func (s *NotificationSettings) Email(typ string) bool {
+	d := s.Delivery(typ)
+	return d == DeliveryEmail || d == DeliveryAll
+}
+
+// Quiet reports whether t falls in the quiet hours, which can span midnight.
+func (s *NotificationSettings) Quiet(t time.Time) bool {
+	start, end, ok := s.quietHours()
+	if !ok {
+		return false
+	}
+
+	local := t.In(s.location())
+	now := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	if from <= to {
+		return from <= now && now < to
+	}
+	return now >= from || now < to
+}
+
+// QuietUntil returns when the quiet hours t falls in are over, t itself when
+// it isn't in quiet hours.
+func (s *NotificationSettings) QuietUntil(t time.Time) time.Time {
+	if !s.Quiet(t) {
+		return t
+	}
+
+	_, end, _ := s.quietHours()
+	local := t.In(s.location())
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+func (s *NotificationSettings) quietHours() (start, end time.Time, ok bool) {
+	if s.QuietStart == "" || s.QuietEnd == "" {
+		return start, end, false
+	}
+
+	start, err := time.Parse("15:04", s.QuietStart)
+	if err != nil {
+		return start, end, false
+	}
+	end, err = time.Parse("15:04", s.QuietEnd)
+	if err != nil {
+		return start, end, false
+	}
+
+	return start, end, true
+}
+
+func (s *NotificationSettings) location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DigestPeriod is how often the digest goes out.
+func (s *NotificationSettings) DigestPeriod() time.Duration {
+	if s.Digest == DigestWeekly {
+		return time.Hour * 24 * 7
+	}
+	return time.Hour * 24
+}
+
+// DigestRecipient is a user with a digest due.
+type DigestRecipient struct {
+	Settings NotificationSettings
+	Username string
+	Email    string
+}
+
+type NotificationSettingStore struct {
+	db *sql.DB
+}
+
+// Get returns the settings of a user, the defaults when they never set any.
+func (s *NotificationSettingStore) Get(ctx context.Context, userID int64) (*NotificationSettings, error) {
+	query := `SELECT deliveries, COALESCE(to_char(quiet_start, 'HH24:MI'), ''), COALESCE(to_char(quiet_end, 'HH24:MI'), ''),
+		timezone, digest, last_digest_at, updated_at
+		FROM notification_settings WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	settings := &NotificationSettings{
+		UserID:   userID,
+		Timezone: "UTC",
+		Digest:   DigestOff,
+	}
+
+	var deliveries []byte
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&deliveries,
+		&settings.QuietStart,
+		&settings.QuietEnd,
+		&settings.Timezone,
+		&settings.Digest,
+		&settings.LastDigestAt,
+		&settings.UpdatedAt,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	settings.Deliveries = make(map[string]string, len(defaultDeliveries))
+	if deliveries != nil {
+		if err := json.Unmarshal(deliveries, &settings.Deliveries); err != nil {
+			return nil, err
+		}
+	}
+	for typ := range defaultDeliveries {
+		settings.Deliveries[typ] = settings.Delivery(typ)
+	}
+
+	return settings, nil
+}
+
+func (s *NotificationSettingStore) Update(ctx context.Context, settings *NotificationSettings) error {
+	query := `INSERT INTO notification_settings (user_id, deliveries, quiet_start, quiet_end, timezone, digest)
+		VALUES ($1, $2, NULLIF($3, '')::time, NULLIF($4, '')::time, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET deliveries = EXCLUDED.deliveries, quiet_start = EXCLUDED.quiet_start,
+		quiet_end = EXCLUDED.quiet_end, timezone = EXCLUDED.timezone, digest = EXCLUDED.digest, updated_at = NOW()
+		RETURNING updated_at`
+
+	deliveries, err := json.Marshal(settings.Deliveries)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, settings.UserID, deliveries, settings.QuietStart, settings.QuietEnd,
+		settings.Timezone, settings.Digest).Scan(&settings.UpdatedAt)
+}
+
+// DueDigests lists the active users after afterID whose digest is due at now.
+func (s *NotificationSettingStore) DueDigests(ctx context.Context, now time.Time, afterID int64, limit int) ([]DigestRecipient, error) {
+	query := `SELECT s.user_id, s.deliveries, COALESCE(to_char(s.quiet_start, 'HH24:MI'), ''), COALESCE(to_char(s.quiet_end, 'HH24:MI'), ''),
+		s.timezone, s.digest, s.last_digest_at, s.updated_at, u.username, u.email
+		FROM notification_settings s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.user_id > $2 AND s.digest <> 'off' AND u.is_active AND
+		(s.last_digest_at IS NULL OR s.last_digest_at <= $1 - CASE s.digest WHEN 'weekly' THEN interval '7 days' ELSE interval '1 day' END)
+		ORDER BY s.user_id
+		LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, now, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []DigestRecipient
+	for rows.Next() {
+		var r DigestRecipient
+		var deliveries []byte
+		if err := rows.Scan(
+			&r.Settings.UserID,
+			&deliveries,
+			&r.Settings.QuietStart,
+			&r.Settings.QuietEnd,
+			&r.Settings.Timezone,
+			&r.Settings.Digest,
+			&r.Settings.LastDigestAt,
+			&r.Settings.UpdatedAt,
+			&r.Username,
+			&r.Email,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(deliveries, &r.Settings.Deliveries); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, rows.Err()
+}
+
+// ClaimDigest marks the digest of a user sent at now, unless another instance
+// did since lastDigestAt was read. claimed reports whether it is ours to send.
+func (s *NotificationSettingStore) ClaimDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, now time.Time) (bool, error) {
+	query := `UPDATE notification_settings SET last_digest_at = $3
+		WHERE user_id = $1 AND last_digest_at IS NOT DISTINCT FROM $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, lastDigestAt, now)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// ReleaseDigest gives back a digest claimed at claimedAt that could not be
+// sent, last_digest_at goes back to lastDigestAt so the next run retries it.
+func (s *NotificationSettingStore) ReleaseDigest(ctx context.Context, userID int64, lastDigestAt *time.Time, claimedAt time.Time) error {
+	query := `UPDATE notification_settings SET last_digest_at = $2
+		WHERE user_id = $1 AND last_digest_at = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, lastDigestAt, claimedAt)
+	return err
+}
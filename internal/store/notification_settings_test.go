@@ -0,0 +1,128 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotificationSettingsQuiet(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		settings NotificationSettings
+		t        time.Time
+		want     bool
+	}{
+		{
+			name:     "should not be quiet without quiet hours",
+			settings: NotificationSettings{Timezone: "UTC"},
+			t:        at(3, 0),
+			want:     false,
+		},
+		{
+			name:     "should be quiet within the same day",
+			settings: NotificationSettings{QuietStart: "13:00", QuietEnd: "15:00", Timezone: "UTC"},
+			t:        at(14, 0),
+			want:     true,
+		},
+		{
+			name:     "should be quiet from the start",
+			settings: NotificationSettings{QuietStart: "13:00", QuietEnd: "15:00", Timezone: "UTC"},
+			t:        at(13, 0),
+			want:     true,
+		},
+		{
+			name:     "should not be quiet from the end",
+			settings: NotificationSettings{QuietStart: "13:00", QuietEnd: "15:00", Timezone: "UTC"},
+			t:        at(15, 0),
+			want:     false,
+		},
+		{
+			name:     "should be quiet before midnight when spanning it",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(23, 30),
+			want:     true,
+		},
+		{
+			name:     "should be quiet after midnight when spanning it",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(6, 59),
+			want:     true,
+		},
+		{
+			name:     "should not be quiet during the day when spanning midnight",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(12, 0),
+			want:     false,
+		},
+		{
+			name:     "should follow the timezone of the user",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "America/New_York"},
+			t:        at(3, 0),
+			want:     true,
+		},
+		{
+			name:     "should fall back to UTC for unknown timezones",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Nowhere/Else"},
+			t:        at(12, 0),
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.Quiet(tt.t); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationSettingsQuietUntil(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		settings NotificationSettings
+		t        time.Time
+		want     time.Time
+	}{
+		{
+			name:     "should not wait outside of quiet hours",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(1, 12, 0),
+			want:     at(1, 12, 0),
+		},
+		{
+			name:     "should wait for the next morning before midnight",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(1, 23, 0),
+			want:     at(2, 7, 0),
+		},
+		{
+			name:     "should wait for the same morning after midnight",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"},
+			t:        at(2, 1, 0),
+			want:     at(2, 7, 0),
+		},
+		{
+			name:     "should wait in the timezone of the user",
+			settings: NotificationSettings{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Europe/Madrid"},
+			t:        at(1, 21, 0),
+			want:     at(2, 5, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.QuietUntil(tt.t); !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationFollow = "follow"
+	// NotificationFollowRequest is for accounts that approve their
+	// followers, nothing sends it until they exist
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationMention       = "mention"
+	// NotificationReaction is for reactions to a post, voting in its poll
+	// is the only one so far
+	NotificationReaction = "reaction"
+)
+
+// notificationActorsShown is how many actors a notification names, the
+// others are counted.
+const notificationActorsShown = 3
+
+// NotificationEvent is something that happened to UserID because of ActorID.
+type NotificationEvent struct {
+	UserID    int64
+	ActorID   int64
+	Type      string
+	PostID    *int64
+	CommentID *int64
+}
+
+// groupKey is shared by the events grouped in one notification: the new
+// followers, the comments on a post, the replies to a comment and the
+// reactions to a post. Mentions are never grouped.
+func (e NotificationEvent) groupKey() string {
+	switch e.Type {
+	case NotificationFollow, NotificationFollowRequest:
+		return e.Type
+	case NotificationComment, NotificationReaction:
+		return fmt.Sprintf("%s:%d", e.Type, deref(e.PostID))
+	case NotificationReply:
+		return fmt.Sprintf("%s:%d", e.Type, deref(e.CommentID))
+	default:
+		return fmt.Sprintf("%s:%d:%d", e.Type, deref(e.PostID), deref(e.CommentID))
+	}
+}
+
+type NotificationActor struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Notification groups similar events, Actors has the latest actors and
+// ActorCount all of them.
+type Notification struct {
+	ID         int64               `json:"id"`
+	Type       string              `json:"type"`
+	PostID     *int64              `json:"post_id,omitempty"`
+	CommentID  *int64              `json:"comment_id,omitempty"`
+	Actors     []NotificationActor `json:"actors"`
+	ActorCount int                 `json:"actor_count"`
+	Summary    string              `json:"summary"`
+	Read       bool                `json:"read"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// Add records e, in the unread notification of its group when there is one.
+// created reports whether it is a new notification.
+func (s *NotificationStore) Add(ctx context.Context, e NotificationEvent) (id int64, created bool, err error) {
+	query := `INSERT INTO notifications (user_id, type, group_key, post_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+		DO UPDATE SET updated_at = NOW()
+		RETURNING id, xmax = 0`
+
+	actorQuery := `INSERT INTO notification_actors (notification_id, actor_id) VALUES ($1, $2)
+		ON CONFLICT (notification_id, actor_id) DO UPDATE SET created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, query, e.UserID, e.Type, e.groupKey(), e.PostID, e.CommentID).Scan(&id, &created); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, actorQuery, id, e.ActorID)
+		return err
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, created, nil
+}
+
+// HoldEmail keeps the email of e until sendAt, when quiet hours are over.
+func (s *NotificationStore) HoldEmail(ctx context.Context, e NotificationEvent, sendAt time.Time) error {
+	query := `INSERT INTO held_notification_emails (user_id, actor_id, type, post_id, comment_id, send_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, e.UserID, e.ActorID, e.Type, e.PostID, e.CommentID, sendAt)
+	return err
+}
+
+// ReleaseHeldEmails removes up to limit held emails due at now and returns
+// their events, the caller sends them. Instances releasing together get
+// different emails.
+func (s *NotificationStore) ReleaseHeldEmails(ctx context.Context, now time.Time, limit int) ([]NotificationEvent, error) {
+	query := `DELETE FROM held_notification_emails WHERE id IN (
+			SELECT id FROM held_notification_emails WHERE send_at <= $1
+			ORDER BY send_at, id LIMIT $2 FOR UPDATE SKIP LOCKED)
+		RETURNING user_id, actor_id, type, post_id, comment_id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []NotificationEvent{}
+	for rows.Next() {
+		var e NotificationEvent
+		if err := rows.Scan(&e.UserID, &e.ActorID, &e.Type, &e.PostID, &e.CommentID); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// List pages through the notifications of userID by latest activity. Add
+// bumps a grouped notification, so one that gets a new event while the user
+// pages moves back to the top: later pages leave it out and the first page
+// shows it again.
+func (s *NotificationStore) List(ctx context.Context, userID int64, nq NotificationsQuery) ([]Notification, bool, error) {
+	cond, order := keysetOn("n.updated_at", "n.id", nq.Cursor, true, "$5", "$6")
+
+	query := `SELECT n.id, n.type, n.post_id, n.comment_id, n.read_at IS NOT NULL, n.created_at, n.updated_at,
+		(SELECT count(*) FROM notification_actors a WHERE a.notification_id = n.id)
+		FROM notifications n
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL) AND n.updated_at > $4 AND ` + cond + `
+		ORDER BY n.updated_at ` + order + `, n.id ` + order + `
+		LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{userID, nq.Unread, nq.Limit + 1, nq.Since}
+	if nq.Cursor != nil {
+		args = append(args, nq.Cursor.CreatedAt, nq.Cursor.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.PostID, &n.CommentID, &n.Read, &n.CreatedAt, &n.UpdatedAt, &n.ActorCount); err != nil {
+			return nil, false, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	notifications, more := trimPage(notifications, nq.Limit, nq.Cursor)
+
+	if err := s.attachActors(ctx, notifications); err != nil {
+		return nil, false, err
+	}
+
+	return notifications, more, nil
+}
+
+// attachActors loads the latest actors of the notifications, most recent
+// first, and sums them up.
+func (s *NotificationStore) attachActors(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Notification, len(notifications))
+	ids := make([]int64, len(notifications))
+	for i := range notifications {
+		n := &notifications[i]
+		n.Actors = []NotificationActor{}
+		byID[n.ID] = n
+		ids[i] = n.ID
+	}
+
+	query := `SELECT a.notification_id, u.id, u.username
+		FROM (
+			SELECT notification_id, actor_id,
+			row_number() OVER (PARTITION BY notification_id ORDER BY created_at DESC, actor_id DESC) AS position
+			FROM notification_actors WHERE notification_id = ANY($1)
+		) a
+		JOIN users u ON u.id = a.actor_id
+		WHERE a.position <= $2
+		ORDER BY a.notification_id, a.position`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids), notificationActorsShown)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notificationID int64
+		var actor NotificationActor
+		if err := rows.Scan(&notificationID, &actor.ID, &actor.Username); err != nil {
+			return err
+		}
+		byID[notificationID].Actors = append(byID[notificationID].Actors, actor)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, n := range byID {
+		n.Summary = summarize(n)
+	}
+
+	return nil
+}
+
+// MarkRead marks a notification of userID read, reading it again is fine.
+func (s *NotificationStore) MarkRead(ctx context.Context, userID, id int64) error {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (s *NotificationStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+var notificationVerbs = map[string]string{
+	NotificationFollow:        "followed you",
+	NotificationFollowRequest: "asked to follow you",
+	NotificationComment:       "commented on your post",
+	NotificationReply:         "replied to your comment",
+	NotificationMention:       "mentioned you",
+	NotificationReaction:      "reacted to your post",
+}
+
+// NotificationAction words what the actors of a notification of type did.
+func NotificationAction(typ string) string {
+	return notificationVerbs[typ]
+}
+
+// summarize words a notification like "alice and 3 others followed you".
+func summarize(n *Notification) string {
+	if len(n.Actors) == 0 {
+		// the actors deleted their accounts
+		return ""
+	}
+
+	names := n.Actors[0].Username
+	switch others := n.ActorCount - 1; {
+	case others == 1 && len(n.Actors) > 1:
+		names += " and " + n.Actors[1].Username
+	case others == 1:
+		names += " and 1 other"
+	case others > 1:
+		names += fmt.Sprintf(" and %d others", others)
+	}
+
+	return strings.TrimSpace(names + " " + notificationVerbs[n.Type])
+}
+
+func deref(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,108 @@
+package store
+
+import "testing"
+
+func TestNotificationGroupKey(t *testing.T) {
+	postID, commentID := int64(7), int64(9)
+
+	tests := []struct {
+		name  string
+		event NotificationEvent
+		want  string
+	}{
+		{
+			name:  "should group every new follower",
+			event: NotificationEvent{Type: NotificationFollow, ActorID: 2},
+			want:  "follow",
+		},
+		{
+			name:  "should group follow requests apart from follows",
+			event: NotificationEvent{Type: NotificationFollowRequest, ActorID: 2},
+			want:  "follow_request",
+		},
+		{
+			name:  "should group the comments on a post",
+			event: NotificationEvent{Type: NotificationComment, PostID: &postID, CommentID: &commentID},
+			want:  "comment:7",
+		},
+		{
+			name:  "should group the reactions to a post",
+			event: NotificationEvent{Type: NotificationReaction, PostID: &postID},
+			want:  "reaction:7",
+		},
+		{
+			name:  "should group the replies to a comment",
+			event: NotificationEvent{Type: NotificationReply, PostID: &postID, CommentID: &commentID},
+			want:  "reply:9",
+		},
+		{
+			name:  "should not group mentions",
+			event: NotificationEvent{Type: NotificationMention, PostID: &postID, CommentID: &commentID},
+			want:  "mention:7:9",
+		},
+		{
+			name:  "should not group mentions in posts with those in comments",
+			event: NotificationEvent{Type: NotificationMention, PostID: &postID},
+			want:  "mention:7:0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.groupKey(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	alice := NotificationActor{ID: 1, Username: "alice"}
+	bob := NotificationActor{ID: 2, Username: "bob"}
+	carol := NotificationActor{ID: 3, Username: "carol"}
+
+	tests := []struct {
+		name string
+		n    Notification
+		want string
+	}{
+		{
+			name: "should name a single actor",
+			n:    Notification{Type: NotificationFollow, Actors: []NotificationActor{alice}, ActorCount: 1},
+			want: "alice followed you",
+		},
+		{
+			name: "should name both of two actors",
+			n:    Notification{Type: NotificationComment, Actors: []NotificationActor{alice, bob}, ActorCount: 2},
+			want: "alice and bob commented on your post",
+		},
+		{
+			name: "should count the other actor when it isn't loaded",
+			n:    Notification{Type: NotificationReply, Actors: []NotificationActor{alice}, ActorCount: 2},
+			want: "alice and 1 other replied to your comment",
+		},
+		{
+			name: "should count the other actors",
+			n:    Notification{Type: NotificationReaction, Actors: []NotificationActor{alice, bob, carol}, ActorCount: 5},
+			want: "alice and 4 others reacted to your post",
+		},
+		{
+			name: "should be empty when the actors are gone",
+			n:    Notification{Type: NotificationMention, ActorCount: 1},
+			want: "",
+		},
+		{
+			name: "should name the actor of unknown types",
+			n:    Notification{Type: "unknown", Actors: []NotificationActor{alice}, ActorCount: 1},
+			want: "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarize(&tt.n); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
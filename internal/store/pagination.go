@@ -5,16 +5,65 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
 )
 
+// keyset returns the condition and the order to read a page from c, in a list
+// of the alias sorted by created_at and id, newest first when desc. Without a
+// cursor the condition is always true. Pages read backward come in reverse
+// order, trimPage puts them back.
+func keyset(alias string, c *cursor.Cursor, desc bool, createdAtParam, idParam string) (string, string) {
+	return keysetOn(alias+".created_at", alias+".id", c, desc, createdAtParam, idParam)
+}
+
+// keysetOn is keyset for lists sorted by another time column than created_at.
+func keysetOn(timeColumn, idColumn string, c *cursor.Cursor, desc bool, timeParam, idParam string) (string, string) {
+	if c == nil {
+		if desc {
+			return "TRUE", "desc"
+		}
+		return "TRUE", "asc"
+	}
+
+	op, order := ">", "asc"
+	if desc != c.Backward {
+		op, order = "<", "desc"
+	}
+
+	return "(" + timeColumn + ", " + idColumn + ") " + op + " (" + timeParam + ", " + idParam + ")", order
+}
+
+// trimPage cuts a page read with one extra item down to limit, reporting
+// whether there were more in the direction it was read.
+func trimPage[T any](items []T, limit int, c *cursor.Cursor) ([]T, bool) {
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+
+	if c != nil && c.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	return items, more
+}
+
+// PaginationFeedQuery pages through a feed either by offset or, when Cursor
+// is set, from a position. Cursors don't skip or repeat posts as new ones
+// arrive, offsets are kept for older clients.
 type PaginationFeedQuery struct {
-	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
-	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
-	Tags   []string `json:"tags" validate:"max=5"`
-	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	Limit  int            `json:"limit" validate:"gte=1,lte=20"`
+	Offset int            `json:"offset" validate:"gte=0"`
+	Cursor *cursor.Cursor `json:"-"`
+	Sort   string         `json:"sort" validate:"oneof=asc desc"`
+	Tags   []string       `json:"tags" validate:"max=5"`
+	Search string         `json:"search" validate:"max=100"`
+	Since  string         `json:"since"`
+	Until  string         `json:"until"`
 }
 
 func (fq PaginationFeedQuery) Parse(r *http.Request) (PaginationFeedQuery, error) {
@@ -38,16 +87,18 @@ func (fq PaginationFeedQuery) Parse(r *http.Request) (PaginationFeedQuery, error
 		fq.Offset = off
 	}
 
-	sort := qs.Get("offsortet")
+	sort := qs.Get("sort")
 	if sort != "" {
 		fq.Sort = sort
 	}
 
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		fq.Tags = parseTags(tags)
 	}
 
+	fq.Search = qs.Get("search")
+
 	since := qs.Get("since")
 	if since != "" {
 		fq.Since = parseTime(since)
@@ -61,6 +112,12 @@ func (fq PaginationFeedQuery) Parse(r *http.Request) (PaginationFeedQuery, error
 	return fq, nil
 }
 
+// parseTags reads a comma separated list of tags, normalized like the tags
+// of posts.
+func parseTags(tags string) []string {
+	return entities.Tags(strings.Split(tags, ","), "")
+}
+
 func parseTime(s string) string {
 	t, err := time.Parse(time.DateTime, s)
 
@@ -70,3 +127,120 @@ func parseTime(s string) string {
 
 	return t.Format(time.DateTime)
 }
+
+// CommentPaginationQuery pages through comments, oldest first, from Cursor
+// or after the comment with the ID After.
+type CommentPaginationQuery struct {
+	Limit    int            `json:"limit" validate:"gte=1,lte=50"`
+	After    int64          `json:"after" validate:"gte=0"`
+	ParentID int64          `json:"parent_id" validate:"gte=0"`
+	Cursor   *cursor.Cursor `json:"-"`
+}
+
+func (cq CommentPaginationQuery) Parse(r *http.Request) (CommentPaginationQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+		cq.Limit = l
+	}
+
+	after := qs.Get("after")
+	if after != "" {
+		a, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return cq, err
+		}
+		cq.After = a
+	}
+
+	parentID := qs.Get("parent_id")
+	if parentID != "" {
+		p, err := strconv.ParseInt(parentID, 10, 64)
+		if err != nil {
+			return cq, err
+		}
+		cq.ParentID = p
+	}
+
+	return cq, nil
+}
+
+// UserPostsQuery pages through the posts of a user, newest first, from Cursor
+// or before the post with the ID Before.
+type UserPostsQuery struct {
+	Limit  int            `json:"limit" validate:"gte=1,lte=20"`
+	Before int64          `json:"before" validate:"gte=0"`
+	Cursor *cursor.Cursor `json:"-"`
+	Tags   []string       `json:"tags" validate:"max=5"`
+	Search string         `json:"search" validate:"max=100"`
+}
+
+func (uq UserPostsQuery) Parse(r *http.Request) (UserPostsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return uq, err
+		}
+		uq.Limit = l
+	}
+
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return uq, err
+		}
+		uq.Before = b
+	}
+
+	tags := qs.Get("tags")
+	if tags != "" {
+		uq.Tags = parseTags(tags)
+	}
+
+	uq.Search = qs.Get("search")
+
+	return uq, nil
+}
+
+// NotificationsQuery pages through the notifications of a user, latest
+// activity first.
+type NotificationsQuery struct {
+	Limit  int            `json:"limit" validate:"gte=1,lte=50"`
+	Unread bool           `json:"unread"`
+	Cursor *cursor.Cursor `json:"-"`
+	// Since leaves out the notifications without activity after it
+	Since time.Time `json:"-"`
+}
+
+func (nq NotificationsQuery) Parse(r *http.Request) (NotificationsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return nq, err
+		}
+		nq.Limit = l
+	}
+
+	unread := qs.Get("unread")
+	if unread != "" {
+		u, err := strconv.ParseBool(unread)
+		if err != nil {
+			return nq, err
+		}
+		nq.Unread = u
+	}
+
+	return nq, nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+)
+
+func TestKeyset(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		c         *cursor.Cursor
+		desc      bool
+		wantWhere string
+		wantOrder string
+	}{
+		{
+			name:      "should not filter the first page",
+			desc:      true,
+			wantWhere: "TRUE",
+			wantOrder: "desc",
+		},
+		{
+			name:      "should read older items forward in a newest first list",
+			c:         &cursor.Cursor{CreatedAt: at, ID: 1},
+			desc:      true,
+			wantWhere: "(p.created_at, p.id) < ($1, $2)",
+			wantOrder: "desc",
+		},
+		{
+			name:      "should read newer items backward in a newest first list",
+			c:         &cursor.Cursor{CreatedAt: at, ID: 1, Backward: true},
+			desc:      true,
+			wantWhere: "(p.created_at, p.id) > ($1, $2)",
+			wantOrder: "asc",
+		},
+		{
+			name:      "should read newer items forward in an oldest first list",
+			c:         &cursor.Cursor{CreatedAt: at, ID: 1},
+			wantWhere: "(p.created_at, p.id) > ($1, $2)",
+			wantOrder: "asc",
+		},
+		{
+			name:      "should read older items backward in an oldest first list",
+			c:         &cursor.Cursor{CreatedAt: at, ID: 1, Backward: true},
+			wantWhere: "(p.created_at, p.id) < ($1, $2)",
+			wantOrder: "desc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, order := keyset("p", tt.c, tt.desc, "$1", "$2")
+			if where != tt.wantWhere || order != tt.wantOrder {
+				t.Errorf("got %q %q, want %q %q", where, order, tt.wantWhere, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestTrimPage(t *testing.T) {
+	t.Run("should cut the extra item and report more", func(t *testing.T) {
+		items, more := trimPage([]int{1, 2, 3}, 2, nil)
+		if !reflect.DeepEqual(items, []int{1, 2}) || !more {
+			t.Errorf("got %v %v, want [1 2] true", items, more)
+		}
+	})
+
+	t.Run("should report the last page", func(t *testing.T) {
+		items, more := trimPage([]int{1, 2}, 2, nil)
+		if !reflect.DeepEqual(items, []int{1, 2}) || more {
+			t.Errorf("got %v %v, want [1 2] false", items, more)
+		}
+	})
+
+	t.Run("should put a page read backward back in order", func(t *testing.T) {
+		items, more := trimPage([]int{3, 2, 1}, 2, &cursor.Cursor{Backward: true})
+		if !reflect.DeepEqual(items, []int{2, 3}) || !more {
+			t.Errorf("got %v %v, want [2 3] true", items, more)
+		}
+	})
+
+	t.Run("should handle empty pages", func(t *testing.T) {
+		items, more := trimPage([]int{}, 2, &cursor.Cursor{Backward: true})
+		if len(items) != 0 || more {
+			t.Errorf("got %v %v, want [] false", items, more)
+		}
+	})
+}
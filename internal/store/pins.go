@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const MaxPinnedPosts = 3
+
+var ErrTooManyPinned = errors.New("you can't pin more than 3 posts")
+
+// Pin pins a published post of userID to their profile. Pinning a post that
+// is already pinned keeps its place.
+func (s *PostStore) Pin(ctx context.Context, postID, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// lock the user so concurrent pins can't go past the limit
+		query := `SELECT id FROM users WHERE id = $1 FOR UPDATE`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		var pinned int
+		query = `SELECT count(*) FROM posts
+				WHERE user_id = $1 AND id <> $2 AND pinned_at IS NOT NULL AND deleted_at IS NULL`
+		if err := tx.QueryRowContext(ctx, query, userID, postID).Scan(&pinned); err != nil {
+			return err
+		}
+
+		if pinned >= MaxPinnedPosts {
+			return ErrTooManyPinned
+		}
+
+		query = `UPDATE posts SET pinned_at = COALESCE(pinned_at, NOW())
+				WHERE id = $1 AND user_id = $2 AND status = 'published' AND deleted_at IS NULL`
+		result, err := tx.ExecContext(ctx, query, postID, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrorNotFound
+		}
+
+		return nil
+	})
+}
+
+// Unpin removes a post from the pins of its author, ErrorNotFound when it
+// wasn't pinned.
+func (s *PostStore) Unpin(ctx context.Context, postID, userID int64) error {
+	query := `UPDATE posts SET pinned_at = NULL WHERE id = $1 AND user_id = $2 AND pinned_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
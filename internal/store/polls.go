@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed      = errors.New("the poll is closed")
+	ErrInvalidPollVote = errors.New("invalid poll options")
+)
+
+// Poll is a poll attached to a post, as seen by one viewer. Votes and
+// percentages are left out while the results are hidden to the viewer.
+type Poll struct {
+	ID             int64        `json:"id"`
+	PostID         int64        `json:"post_id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	HideResults    bool         `json:"hide_results_until_voted"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	Closed         bool         `json:"closed"`
+	Voters         *int         `json:"voters,omitempty"`
+	Voted          bool         `json:"voted"`
+	ResultsHidden  bool         `json:"results_hidden"`
+	Options        []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	ID      int64    `json:"id"`
+	Text    string   `json:"text"`
+	Votes   *int     `json:"votes,omitempty"`
+	Percent *float64 `json:"percent,omitempty"`
+	Voted   bool     `json:"voted"`
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// GetByPostID returns the poll of a post as seen by viewerID, nil when the
+// post has no poll.
+func (s *PollStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	polls, err := pollsByPostID(ctx, s.db, viewerID, []int64{postID})
+	if err != nil {
+		return nil, err
+	}
+
+	return polls[postID], nil
+}
+
+// Vote replaces the vote of userID on a poll with optionIDs. Votes can be
+// changed until the poll closes.
+func (s *PollStore) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var (
+			multipleChoice bool
+			expiresAt      time.Time
+		)
+
+		// the row lock serializes the votes of a poll, so a user voting
+		// twice at once can't end up with two answers on a single choice poll
+		query := `SELECT multiple_choice, expires_at FROM polls WHERE id = $1 FOR UPDATE`
+		err := tx.QueryRowContext(ctx, query, pollID).Scan(&multipleChoice, &expiresAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		if !expiresAt.After(time.Now()) {
+			return ErrPollClosed
+		}
+
+		if len(optionIDs) == 0 || (!multipleChoice && len(optionIDs) > 1) {
+			return ErrInvalidPollVote
+		}
+
+		var matching int
+		query = `SELECT count(*) FROM poll_options WHERE poll_id = $1 AND id = ANY($2)`
+		if err := tx.QueryRowContext(ctx, query, pollID, pq.Array(optionIDs)).Scan(&matching); err != nil {
+			return err
+		}
+
+		if matching != len(optionIDs) {
+			return ErrInvalidPollVote
+		}
+
+		query = `DELETE FROM poll_votes WHERE poll_id = $1 AND user_id = $2`
+		if _, err := tx.ExecContext(ctx, query, pollID, userID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO poll_votes (poll_id, option_id, user_id)
+				SELECT $1, option_id, $3 FROM unnest($2::bigint[]) AS option_id`
+		_, err = tx.ExecContext(ctx, query, pollID, pq.Array(optionIDs), userID)
+		return err
+	})
+}
+
+// createPoll stores the poll of a post, in the transaction creating the post.
+func createPoll(ctx context.Context, tx *sql.Tx, poll *Poll) error {
+	query := `INSERT INTO polls (post_id, multiple_choice, hide_results, expires_at)
+			VALUES ($1, $2, $3, $4) RETURNING id`
+
+	err := tx.QueryRowContext(ctx, query, poll.PostID, poll.MultipleChoice, poll.HideResults, poll.ExpiresAt).
+		Scan(&poll.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3) RETURNING id`
+	for i := range poll.Options {
+		err := tx.QueryRowContext(ctx, query, poll.ID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachPolls loads the polls of posts as seen by viewerID.
+func attachPolls(ctx context.Context, db *sql.DB, viewerID int64, posts ...*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	polls, err := pollsByPostID(ctx, db, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Poll = polls[post.ID]
+	}
+
+	return nil
+}
+
+func pollsByPostID(ctx context.Context, db *sql.DB, viewerID int64, postIDs []int64) (map[int64]*Poll, error) {
+	polls := make(map[int64]*Poll)
+	if len(postIDs) == 0 {
+		return polls, nil
+	}
+
+	query := `SELECT pl.id, pl.post_id, pl.multiple_choice, pl.hide_results, pl.expires_at, p.user_id,
+			(SELECT count(DISTINCT v.user_id) FROM poll_votes v WHERE v.poll_id = pl.id) AS voters
+			FROM polls pl JOIN posts p ON p.id = pl.post_id
+			WHERE pl.post_id = ANY($1)`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*Poll)
+	authors := make(map[int64]int64)
+	for rows.Next() {
+		var (
+			poll   Poll
+			author int64
+			voters int
+		)
+		err := rows.Scan(&poll.ID, &poll.PostID, &poll.MultipleChoice, &poll.HideResults, &poll.ExpiresAt, &author, &voters)
+		if err != nil {
+			return nil, err
+		}
+
+		poll.Closed = !poll.ExpiresAt.After(time.Now())
+		poll.Voters = &voters
+		poll.Options = []PollOption{}
+		polls[poll.PostID] = &poll
+		byID[poll.ID] = &poll
+		authors[poll.ID] = author
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(byID) == 0 {
+		return polls, nil
+	}
+
+	query = `SELECT o.id, o.poll_id, o.text, count(v.user_id) AS votes,
+			COALESCE(bool_or(v.user_id = $2), false) AS voted
+			FROM poll_options o JOIN polls pl ON pl.id = o.poll_id
+			LEFT JOIN poll_votes v ON v.option_id = o.id
+			WHERE pl.post_id = ANY($1)
+			GROUP BY o.id
+			ORDER BY o.poll_id, o.position`
+
+	optionRows, err := db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var (
+			option PollOption
+			pollID int64
+			votes  int
+		)
+		if err := optionRows.Scan(&option.ID, &pollID, &option.Text, &votes, &option.Voted); err != nil {
+			return nil, err
+		}
+
+		poll := byID[pollID]
+		percent := 0.0
+		if *poll.Voters > 0 {
+			// percentages of voters, they add up to more than 100 on
+			// multiple choice polls
+			percent = float64(votes) * 100 / float64(*poll.Voters)
+		}
+		option.Votes = &votes
+		option.Percent = &percent
+		poll.Voted = poll.Voted || option.Voted
+		poll.Options = append(poll.Options, option)
+	}
+
+	if err := optionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for id, poll := range byID {
+		if poll.HideResults && !poll.Voted && !poll.Closed && authors[id] != viewerID {
+			hideResults(poll)
+		}
+	}
+
+	return polls, nil
+}
+
+func hideResults(poll *Poll) {
+	poll.ResultsHidden = true
+	poll.Voters = nil
+	for i := range poll.Options {
+		poll.Options[i].Votes = nil
+		poll.Options[i].Percent = nil
+	}
+}
@@ -4,52 +4,147 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"time"
 
 	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID          int64    `json:"id"`
+	Content     string   `json:"content"`
+	ContentHTML string   `json:"content_html"`
+	Title       string   `json:"title"`
+	UserID      int64    `json:"user_id"`
+	Tags        []string `json:"tags"`
+	Status      string   `json:"status"`
+	Visibility  string   `json:"visibility"`
+	Language    string   `json:"language,omitempty"`
+	// ContentWarning and Sensitive flag the post, clients show it collapsed
+	// behind the warning when Collapsed is set for the viewer.
+	ContentWarning string `json:"content_warning"`
+	Sensitive      bool   `json:"sensitive"`
+	// WarningForced is set when a moderator put the warning on the post.
+	WarningForced bool       `json:"content_warning_forced"`
+	Collapsed     bool       `json:"collapsed"`
+	PublishAt     *time.Time `json:"publish_at"`
+	// ExpiresAt is when an ephemeral post disappears, nil for posts that stay.
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
+	PinnedAt    *time.Time   `json:"pinned_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Version     int          `json:"version"`
+	Mentions    []Mention    `json:"mentions"`
+	Poll        *Poll        `json:"poll,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+	Preview     *LinkPreview `json:"preview,omitempty"`
+	Comments    []Comment    `json:"comments"`
+	User        User         `json:"user"`
 }
 
 type PostWithMetadata struct {
 	Post
+	// CommentCount leaves out deleted comments, their tombstones still
+	// show in the threads.
 	CommentCount int `json:"comments_count"`
 }
 
+// feedPosts points to the posts of a feed, to fill them in place.
+func feedPosts(feed []PostWithMetadata) []*Post {
+	posts := make([]*Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	return posts
+}
+
 type PostStore struct {
 	db *sql.DB
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `INSERT INTO posts (content, title, user_id, tags)
-			VALUES ($1, $2, $3, $4)	RETURNING id, created_at, updated_at`
+	query := `INSERT INTO posts (content, title, user_id, tags, status, publish_at, visibility, content_html, content_warning, sensitive, expires_at, language)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)	RETURNING id, created_at, updated_at`
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	if post.Language == "" {
+		post.Language = DefaultLanguage
+	}
+	post.Tags = entities.Tags(post.Tags, post.Content)
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags)).
-		Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		html, err := renderContent(ctx, tx, post.Content)
+		if err != nil {
+			return err
+		}
+		post.ContentHTML = html
+
+		err = tx.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags), post.Status, post.PublishAt, post.Visibility, post.ContentHTML,
+			post.ContentWarning, post.Sensitive, post.ExpiresAt, post.Language).
+			Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		if err := setPostMentions(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if err := setPostPreview(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if err := setPostAttachments(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			post.Poll.PostID = post.ID
+			if err := createPoll(ctx, tx, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		return createPostRevision(ctx, tx, post, post.UserID)
+	})
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := attachPostMentions(ctx, s.db, post); err != nil {
+		return err
+	}
+
+	if err := attachMedia(ctx, s.db, post); err != nil {
+		return err
+	}
+
+	if err := attachPreviews(ctx, s.db, post); err != nil {
+		return err
+	}
+
+	return attachPolls(ctx, s.db, post.UserID, post)
 }
 
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id, content, title, user_id, tags, created_at, updated_at, version
-			FROM posts WHERE id = $1`
+	query := `SELECT id, content, COALESCE(content_html, ''), title, user_id, tags, status, visibility, language, content_warning, sensitive,
+			content_warning_forced, publish_at, expires_at, created_at, updated_at, version
+			FROM posts WHERE id = $1 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
 
 	post := &Post{}
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -58,9 +153,18 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		Scan(
 			&post.ID,
 			&post.Content,
+			&post.ContentHTML,
 			&post.Title,
 			&post.UserID,
 			pq.Array(&post.Tags),
+			&post.Status,
+			&post.Visibility,
+			&post.Language,
+			&post.ContentWarning,
+			&post.Sensitive,
+			&post.WarningForced,
+			&post.PublishAt,
+			&post.ExpiresAt,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 			&post.Version,
@@ -75,16 +179,34 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		}
 	}
 
+	if err := attachPostMentions(ctx, s.db, post); err != nil {
+		return nil, err
+	}
+
+	renderMissingPosts(post)
+
+	if err := attachMedia(ctx, s.db, post); err != nil {
+		return nil, err
+	}
+
+	if err := attachPreviews(ctx, s.db, post); err != nil {
+		return nil, err
+	}
+
 	return post, nil
 }
 
-func (s *PostStore) Delete(ctx context.Context, postID int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+// Delete moves a post to the trash, it can be restored until it is purged.
+// It loses its pin, so it doesn't count against the limit while in the trash.
+// Delete moves a post to the trash. deleterID is the user deleting it, only
+// posts the author deleted themselves can be restored from the trash.
+func (s *PostStore) Delete(ctx context.Context, postID, deleterID int64) error {
+	query := `UPDATE posts SET deleted_at = NOW(), deleted_by = $2, pinned_at = NULL WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, query, postID)
+	result, err := s.db.ExecContext(ctx, query, postID, deleterID)
 	if err != nil {
 		return err
 	}
@@ -95,67 +217,253 @@ func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		log.Println("0 posts rows affected")
-		return nil
+		return ErrorNotFound
 	}
 
 	return nil
 }
 
-func (s *PostStore) Update(ctx context.Context, post *Post) (*Post, error) {
+// GetTrash lists the posts a user deleted after since, newest first. Posts
+// removed by someone else don't show up, they can't be restored.
+func (s *PostStore) GetTrash(ctx context.Context, userID int64, since time.Time) ([]Post, error) {
+	query := `SELECT id, content, COALESCE(content_html, ''), title, user_id, tags, status, visibility, content_warning, sensitive,
+			content_warning_forced, publish_at, expires_at, deleted_at, created_at, updated_at, version
+			FROM posts
+			WHERE user_id = $1 AND deleted_by = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+			ORDER BY deleted_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID,
+			&post.Content,
+			&post.ContentHTML,
+			&post.Title,
+			&post.UserID,
+			pq.Array(&post.Tags),
+			&post.Status,
+			&post.Visibility,
+			&post.ContentWarning,
+			&post.Sensitive,
+			&post.WarningForced,
+			&post.PublishAt,
+			&post.ExpiresAt,
+			&post.DeletedAt,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		renderMissing(&post.ContentHTML, post.Content, nil)
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// Restore takes a post of userID out of the trash, as long as they deleted it
+// themselves after since.
+func (s *PostStore) Restore(ctx context.Context, postID, userID int64, since time.Time) error {
+	query := `UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+			WHERE id = $1 AND user_id = $2 AND deleted_by = $2 AND deleted_at IS NOT NULL AND deleted_at > $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, postID, userID, since)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes up to limit posts deleted before the given
+// time along with their comments, and returns their IDs. Rows taken by
+// another instance are skipped.
+func (s *PostStore) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	query := `WITH purged AS (
+				SELECT id FROM posts
+				WHERE deleted_at IS NOT NULL AND deleted_at < $1
+				ORDER BY deleted_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			), purged_comments AS (
+				DELETE FROM comments WHERE post_id IN (SELECT id FROM purged)
+			)
+			DELETE FROM posts WHERE id IN (SELECT id FROM purged)
+			RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Update saves post as a new version and records it in the post history.
+// editorID is the user making the change, who may not be the author. A draft
+// published by the update is dated now, like scheduled posts.
+func (s *PostStore) Update(ctx context.Context, post *Post, editorID int64) (*Post, error) {
 	query := `UPDATE posts
-			SET title = $1, content = $2, tags = $3, updated_at = NOW(), version = version + 1
-			WHERE id = $4 and version = $5
-			RETURNING  content, title, tags, updated_at, version`
+			SET title = $1, content = $2, tags = $3, status = $6, publish_at = $7, visibility = $8, content_html = $9,
+			content_warning = $10, sensitive = $11, updated_at = NOW(), version = version + 1,
+			created_at = CASE WHEN status <> 'published' AND $6 = 'published' THEN NOW() ELSE created_at END
+			WHERE id = $4 and version = $5 and deleted_at IS NULL
+			RETURNING  content, title, tags, status, visibility, content_warning, sensitive, content_warning_forced,
+			publish_at, expires_at, created_at, updated_at, version`
+
+	post.Tags = entities.Tags(post.Tags, post.Content)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query,
-		post.Title,
-		post.Content,
-		pq.Array(post.Tags),
-		post.ID,
-		post.Version,
-	).Scan(
-		&post.Content,
-		&post.Title,
-		pq.Array(&post.Tags),
-		&post.UpdatedAt,
-		&post.Version,
-	)
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		html, err := renderContent(ctx, tx, post.Content)
+		if err != nil {
+			return err
+		}
+		post.ContentHTML = html
+
+		err = tx.QueryRowContext(ctx, query,
+			post.Title,
+			post.Content,
+			pq.Array(post.Tags),
+			post.ID,
+			post.Version,
+			post.Status,
+			post.PublishAt,
+			post.Visibility,
+			post.ContentHTML,
+			post.ContentWarning,
+			post.Sensitive,
+		).Scan(
+			&post.Content,
+			&post.Title,
+			pq.Array(&post.Tags),
+			&post.Status,
+			&post.Visibility,
+			&post.ContentWarning,
+			&post.Sensitive,
+			&post.WarningForced,
+			&post.PublishAt,
+			&post.ExpiresAt,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+		)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				// someone else updated the post since it was read
+				return ErrorConflict
+			default:
+				return err
+			}
+		}
+
+		if err := setPostMentions(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if err := setPostPreview(ctx, tx, post); err != nil {
+			return err
+		}
+
+		return createPostRevision(ctx, tx, post, editorID)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := attachPostMentions(ctx, s.db, post); err != nil {
+		return nil, err
+	}
+
+	if err := attachPreviews(ctx, s.db, post); err != nil {
+		return nil, err
+	}
+
 	return post, nil
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginationFeedQuery) ([]PostWithMetadata, error) {
+// GetUserFeed returns a page of the home feed of userID and whether more
+// posts follow in the direction it was read.
+func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginationFeedQuery) ([]PostWithMetadata, bool, error) {
+	cond, order := keyset("p", fq.Cursor, fq.Sort == "desc", "$6", "$7")
+
 	query := `
-	select p.id, p.user_id, p.title,p.content, p.created_at, p.version, p.tags,u.username, count(c.id) as comments_count
-	from Posts p left join Comments c on c.post_id = p.id
+	select p.id, p.user_id, p.title,p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status, p.visibility,
+	p.content_warning, p.sensitive, p.content_warning_forced, p.expires_at, u.username, count(c.id) as comments_count
+	from Posts p left join Comments c on c.post_id = p.id AND c.deleted_at IS NULL
 	left join users u On p.user_id = u.id
-	join followers f on f.follower_id = p.user_id or p.user_id = $1
-	where f.user_id = $1 or p.user_id = $1 AND
+	where (p.user_id = $1 or p.user_id in (select f.user_id from followers f where f.follower_id = $1)) AND
+	p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$1") + ` AND ` + displayableTo("$1") + ` AND
 	(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-	(p.tags @> $5 OR $5= '{}')
+	(p.tags @> $5 OR $5= '{}') AND ` + cond + `
 	group by p.id, u.username
-	order by p.created_at ` + fq.Sort + `
+	order by p.created_at ` + order + `, p.id ` + order + `
 	LIMIT $2 OFFSET $3
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	tags := pq.Array(fq.Tags)
+	if fq.Tags == nil {
+		tags = pq.Array([]string{})
+	}
+
+	// one more post than asked tells whether there is a next page
+	args := []any{userID, fq.Limit + 1, fq.Offset, fq.Search, tags}
+	if fq.Cursor != nil {
+		args = append(args, fq.Cursor.CreatedAt, fq.Cursor.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed := []PostWithMetadata{}
 
 	for rows.Next() {
 		var p PostWithMetadata
@@ -164,18 +472,209 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq Pagination
 			&p.UserID,
 			&p.Title,
 			&p.Content,
+			&p.ContentHTML,
 			&p.CreatedAt,
 			&p.Version,
 			pq.Array(&p.Tags),
+			&p.Status,
+			&p.Visibility,
+			&p.ContentWarning,
+			&p.Sensitive,
+			&p.WarningForced,
+			&p.ExpiresAt,
 			&p.User.Username,
 			&p.CommentCount,
 		)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		feed = append(feed, p)
 	}
 
-	return feed, nil
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	feed, more := trimPage(feed, fq.Limit, fq.Cursor)
+
+	if err := attachPostMentions(ctx, s.db, feedPosts(feed)...); err != nil {
+		return nil, false, err
+	}
+
+	renderMissingPosts(feedPosts(feed)...)
+
+	if err := attachMedia(ctx, s.db, feedPosts(feed)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachPreviews(ctx, s.db, feedPosts(feed)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachPolls(ctx, s.db, userID, feedPosts(feed)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachDisplay(ctx, s.db, userID, feedPosts(feed)...); err != nil {
+		return nil, false, err
+	}
+
+	return feed, more, nil
+}
+
+// GetUserPosts returns the published posts of userID that viewerID can see,
+// newest first. The first page starts with the pinned posts, the following
+// ones only page through the others. It reports whether more posts follow in
+// the direction the page was read.
+func (s *PostStore) GetUserPosts(ctx context.Context, userID, viewerID int64, uq UserPostsQuery) ([]PostWithMetadata, bool, error) {
+	cond, order := keyset("p", uq.Cursor, true, "$7", "$8")
+
+	query := `
+	select p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status,
+	p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.pinned_at,
+	p.expires_at, u.username,
+	(select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL) as comments_count
+	from posts p join users u on u.id = p.user_id
+	where p.user_id = $1 AND p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$2") + ` AND ` + displayableTo("$2") + ` AND
+	(p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+	(p.tags @> $4 OR $4 = '{}') AND `
+
+	pinnedQuery := query + `p.pinned_at IS NOT NULL
+	order by p.pinned_at desc`
+
+	query += `p.pinned_at IS NULL AND
+	($5 = 0 OR (p.created_at, p.id) < (select b.created_at, b.id from posts b where b.id = $5)) AND ` + cond + `
+	order by p.created_at ` + order + `, p.id ` + order + `
+	LIMIT $6`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tags := pq.Array(uq.Tags)
+	if uq.Tags == nil {
+		tags = pq.Array([]string{})
+	}
+
+	posts := []PostWithMetadata{}
+	if uq.Before == 0 && uq.Cursor == nil {
+		pinned, err := s.scanPosts(ctx, pinnedQuery, userID, viewerID, uq.Search, tags)
+		if err != nil {
+			return nil, false, err
+		}
+		posts = append(posts, pinned...)
+	}
+
+	args := []any{userID, viewerID, uq.Search, tags, uq.Before, uq.Limit + 1}
+	if uq.Cursor != nil {
+		args = append(args, uq.Cursor.CreatedAt, uq.Cursor.ID)
+	}
+
+	page, err := s.scanPosts(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	page, more := trimPage(page, uq.Limit, uq.Cursor)
+	posts = append(posts, page...)
+
+	if err := attachPostMentions(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, false, err
+	}
+
+	renderMissingPosts(feedPosts(posts)...)
+
+	if err := attachMedia(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachPreviews(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachPolls(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, false, err
+	}
+
+	if err := attachDisplay(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, false, err
+	}
+
+	return posts, more, nil
+}
+
+// scanPosts runs a listing query selecting the columns of GetUserPosts.
+func (s *PostStore) scanPosts(ctx context.Context, query string, args ...any) ([]PostWithMetadata, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Status,
+			&p.Visibility,
+			&p.ContentWarning,
+			&p.Sensitive,
+			&p.WarningForced,
+			&p.PinnedAt,
+			&p.ExpiresAt,
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// PublishScheduled publishes up to limit scheduled posts whose time has come
+// and returns their IDs. Rows taken by another instance are skipped, so it is
+// safe to run from every API instance at the same time. Published posts are
+// dated when they go out, not when they were written, so feeds don't bury them.
+func (s *PostStore) PublishScheduled(ctx context.Context, limit int) ([]int64, error) {
+	query := `UPDATE posts SET status = 'published', created_at = NOW(), updated_at = NOW()
+			WHERE id IN (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+				ORDER BY publish_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	FlaggedContentWarn   = "warn"
+	FlaggedContentExpand = "expand"
+	FlaggedContentHide   = "hide"
+)
+
+// Preferences are the per account display settings.
+type Preferences struct {
+	UserID int64 `json:"-"`
+	// FlaggedContent is how posts with a content warning or marked sensitive
+	// are shown: collapsed behind the warning, expanded, or left out of feeds.
+	FlaggedContent string    `json:"flagged_content"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type PreferenceStore struct {
+	db *sql.DB
+}
+
+// Get returns the preferences of a user, the defaults when they never set any.
+func (s *PreferenceStore) Get(ctx context.Context, userID int64) (*Preferences, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getPreferences(ctx, s.db, userID)
+}
+
+func (s *PreferenceStore) Update(ctx context.Context, prefs *Preferences) error {
+	query := `INSERT INTO user_preferences (user_id, flagged_content) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET flagged_content = EXCLUDED.flagged_content, updated_at = NOW()
+			RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, prefs.UserID, prefs.FlaggedContent).Scan(&prefs.UpdatedAt)
+}
+
+func getPreferences(ctx context.Context, db *sql.DB, userID int64) (*Preferences, error) {
+	prefs := &Preferences{
+		UserID:         userID,
+		FlaggedContent: FlaggedContentWarn,
+	}
+
+	if userID == 0 {
+		return prefs, nil
+	}
+
+	query := `SELECT flagged_content, updated_at FROM user_preferences WHERE user_id = $1`
+
+	err := db.QueryRowContext(ctx, query, userID).Scan(&prefs.FlaggedContent, &prefs.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// Flagged reports whether the post is behind a content warning.
+func (p *Post) Flagged() bool {
+	return p.Sensitive || p.ContentWarning != ""
+}
+
+// ApplyDisplay collapses the flagged posts of others unless the viewer chose
+// to expand them.
+func ApplyDisplay(prefs *Preferences, posts ...*Post) {
+	for _, post := range posts {
+		post.Collapsed = post.Flagged() && post.UserID != prefs.UserID &&
+			prefs.FlaggedContent != FlaggedContentExpand
+	}
+}
+
+// attachDisplay applies the preferences of viewerID to a feed.
+func attachDisplay(ctx context.Context, db *sql.DB, viewerID int64, posts ...*Post) error {
+	prefs, err := getPreferences(ctx, db, viewerID)
+	if err != nil {
+		return err
+	}
+
+	ApplyDisplay(prefs, posts...)
+	return nil
+}
+
+// displayableTo is the SQL condition on the posts alias p that leaves flagged
+// posts out of the feeds of viewers who chose to hide them. Their own posts
+// are always shown.
+func displayableTo(viewerParam string) string {
+	return strings.ReplaceAll(`(p.user_id = $viewer OR (NOT p.sensitive AND p.content_warning = '') OR NOT EXISTS (
+		SELECT 1 FROM user_preferences dp WHERE dp.user_id = $viewer AND dp.flagged_content = 'hide'))`, "$viewer", viewerParam)
+}
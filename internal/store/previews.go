@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+)
+
+const (
+	// linkPreviewTTL is how long a fetched preview is reused before a new
+	// post linking to the URL gets it fetched again.
+	linkPreviewTTL = 7 * 24 * time.Hour
+	// linkPreviewClaimTimeout frees the URLs of a worker that died mid fetch.
+	linkPreviewClaimTimeout = 5 * time.Minute
+)
+
+// LinkPreview is the card of the first link in a post.
+type LinkPreview struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"image_url"`
+	SiteName    string    `json:"site_name"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+type LinkPreviewStore struct {
+	db *sql.DB
+}
+
+// ClaimPending takes up to limit URLs waiting for their preview. Rows taken
+// by another instance are skipped.
+func (s *LinkPreviewStore) ClaimPending(ctx context.Context, limit int) ([]string, error) {
+	query := `UPDATE link_previews SET status = 'fetching', claimed_at = NOW()
+			WHERE url IN (
+				SELECT url FROM link_previews
+				WHERE status = 'pending'
+				OR (status = 'fetching' AND claimed_at < NOW() - make_interval(secs => $2))
+				ORDER BY created_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			) RETURNING url`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, linkPreviewClaimTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := []string{}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, rows.Err()
+}
+
+func (s *LinkPreviewStore) Save(ctx context.Context, preview *LinkPreview) error {
+	query := `UPDATE link_previews
+			SET status = 'ready', title = $2, description = $3, image_url = $4, site_name = $5, fetched_at = NOW()
+			WHERE url = $1
+			RETURNING fetched_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName).
+		Scan(&preview.FetchedAt)
+}
+
+// MarkFailed records that url has no preview, it is retried once the TTL is
+// over like a fetched one.
+func (s *LinkPreviewStore) MarkFailed(ctx context.Context, url string) error {
+	query := `UPDATE link_previews SET status = 'failed', fetched_at = NOW() WHERE url = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, url)
+	return err
+}
+
+// setPostPreview points the post to the preview of its first link and queues
+// the URL when its preview is missing or stale.
+func setPostPreview(ctx context.Context, tx *sql.Tx, post *Post) error {
+	var previewURL *string
+	if urls := entities.URLs(post.Content); len(urls) > 0 {
+		previewURL = &urls[0].Text
+	}
+
+	query := `UPDATE posts SET preview_url = $2 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, post.ID, previewURL); err != nil {
+		return err
+	}
+
+	if previewURL == nil {
+		return nil
+	}
+
+	query = `INSERT INTO link_previews (url) VALUES ($1)
+			ON CONFLICT (url) DO UPDATE SET status = 'pending', created_at = NOW()
+			WHERE link_previews.status IN ('ready', 'failed')
+			AND link_previews.fetched_at < NOW() - make_interval(secs => $2)`
+
+	_, err := tx.ExecContext(ctx, query, *previewURL, linkPreviewTTL.Seconds())
+	return err
+}
+
+// attachPreviews loads the fetched previews of posts. A preview being
+// refreshed keeps its previous card, one never fetched has none.
+func attachPreviews(ctx context.Context, db *sql.DB, posts ...*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	query := `SELECT p.id, lp.url, lp.title, lp.description, lp.image_url, lp.site_name, lp.fetched_at
+			FROM posts p JOIN link_previews lp ON lp.url = p.preview_url
+			WHERE p.id = ANY($1) AND lp.fetched_at IS NOT NULL AND lp.status <> 'failed'`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	previews := make(map[int64]*LinkPreview)
+	for rows.Next() {
+		var (
+			postID int64
+			lp     LinkPreview
+		)
+		if err := rows.Scan(&postID, &lp.URL, &lp.Title, &lp.Description, &lp.ImageURL, &lp.SiteName, &lp.FetchedAt); err != nil {
+			return err
+		}
+		previews[postID] = &lp
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Preview = previews[post.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
+)
+
+// AffinityWindow is how far back the interactions of a viewer with an author
+// count towards their affinity.
+const AffinityWindow = 30 * 24 * time.Hour
+
+// GetRankingCandidates returns the posts published since by viewerID and the
+// users they follow that the viewer can see in a feed, newest first, with
+// their engagement and the affinity of the viewer with their authors.
+func (s *PostStore) GetRankingCandidates(ctx context.Context, viewerID int64, since time.Time, limit int) ([]ranking.Candidate, error) {
+	query := `
+	select p.id, p.user_id, p.created_at,
+	(select count(*) from comments c where c.post_id = p.id and c.deleted_at is null),
+	(select count(distinct v.user_id) from poll_votes v join polls pl on pl.id = v.poll_id where pl.post_id = p.id)
+	from posts p
+	where (p.user_id = $1 or p.user_id in (select f.user_id from followers f where f.follower_id = $1)) AND
+	p.status = 'published' AND p.deleted_at IS NULL AND p.created_at > $2 AND
+	` + visibleTo("$1") + ` AND ` + displayableTo("$1") + `
+	order by p.created_at desc, p.id desc
+	limit $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []ranking.Candidate{}
+	authors := map[int64]bool{}
+	for rows.Next() {
+		var c ranking.Candidate
+		if err := rows.Scan(&c.PostID, &c.AuthorID, &c.CreatedAt, &c.Comments, &c.Reactions); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+		authors[c.AuthorID] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	affinity, err := s.authorAffinity(ctx, viewerID, authors, time.Now().Add(-AffinityWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		candidates[i].Affinity = affinity[candidates[i].AuthorID]
+	}
+
+	return candidates, nil
+}
+
+// authorAffinity counts the comments and poll votes viewerID left on the
+// posts of each author since the given time.
+func (s *PostStore) authorAffinity(ctx context.Context, viewerID int64, authors map[int64]bool, since time.Time) (map[int64]int, error) {
+	affinity := make(map[int64]int)
+
+	ids := make([]int64, 0, len(authors))
+	for id := range authors {
+		if id != viewerID {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return affinity, nil
+	}
+
+	query := `SELECT author_id, count(*) FROM (
+				SELECT p.user_id AS author_id FROM comments c JOIN posts p ON p.id = c.post_id
+				WHERE c.user_id = $1 AND c.created_at > $2
+				UNION ALL
+				SELECT p.user_id FROM poll_votes v JOIN polls pl ON pl.id = v.poll_id JOIN posts p ON p.id = pl.post_id
+				WHERE v.user_id = $1 AND v.created_at > $2
+			) i
+			WHERE author_id = ANY($3)
+			GROUP BY author_id`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, since, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			authorID int64
+			count    int
+		)
+		if err := rows.Scan(&authorID, &count); err != nil {
+			return nil, err
+		}
+		affinity[authorID] = count
+	}
+
+	return affinity, rows.Err()
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/entities"
+	"ontopsolutions.net/gasperlf/social/internal/markdown"
+)
+
+// renderContent renders content when it is written, so reads serve the
+// stored HTML of the current version instead of rendering it every time.
+func renderContent(ctx context.Context, tx *sql.Tx, content string) (string, error) {
+	users := make(map[string]bool)
+
+	usernames := entities.MentionedUsernames(content)
+	if len(usernames) > 0 {
+		query := `SELECT username FROM users WHERE username = ANY($1)`
+
+		rows, err := tx.QueryContext(ctx, query, pq.Array(usernames))
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var username string
+			if err := rows.Scan(&username); err != nil {
+				return "", err
+			}
+			users[username] = true
+		}
+
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+	}
+
+	return markdown.Render(content, func(username string) bool { return users[username] }), nil
+}
+
+// renderMissing renders content written before HTML was stored, from its
+// resolved mentions.
+func renderMissing(html *string, content string, mentions []Mention) {
+	if *html != "" {
+		return
+	}
+
+	users := make(map[string]bool, len(mentions))
+	for _, m := range mentions {
+		users[m.Username] = true
+	}
+
+	*html = markdown.Render(content, func(username string) bool { return users[username] })
+}
+
+// renderMissingPosts renders the posts read without stored HTML, once their
+// mentions are attached.
+func renderMissingPosts(posts ...*Post) {
+	for _, post := range posts {
+		renderMissing(&post.ContentHTML, post.Content, post.Mentions)
+	}
+}
+
+// renderMissingComments is renderMissingPosts for comments.
+func renderMissingComments(comments ...*Comment) {
+	for _, comment := range comments {
+		renderMissing(&comment.ContentHTML, comment.Content, comment.Mentions)
+	}
+}
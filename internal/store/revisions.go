@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PostRevision struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	EditorID  int64     `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Editor    User      `json:"editor"`
+}
+
+type PostRevisionStore struct {
+	db *sql.DB
+}
+
+func (s *PostRevisionStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `SELECT r.id, r.post_id, r.version, r.title, r.content, r.tags, r.editor_id, r.created_at, u.username
+			FROM post_revisions r JOIN users u ON u.id = r.editor_id
+			WHERE r.post_id = $1
+			ORDER BY r.version DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		revision, err := scanPostRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *PostRevisionStore) GetByVersion(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `SELECT r.id, r.post_id, r.version, r.title, r.content, r.tags, r.editor_id, r.created_at, u.username
+			FROM post_revisions r JOIN users u ON u.id = r.editor_id
+			WHERE r.post_id = $1 AND r.version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	revision, err := scanPostRevision(s.db.QueryRowContext(ctx, query, postID, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+// createPostRevision snapshots the current state of post, it runs in the same
+// transaction that writes the post so history and post never diverge.
+func createPostRevision(ctx context.Context, tx *sql.Tx, post *Post, editorID int64) error {
+	query := `INSERT INTO post_revisions (post_id, version, title, content, tags, editor_id)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.ExecContext(ctx, query, post.ID, post.Version, post.Title, post.Content, pq.Array(post.Tags), editorID)
+	return err
+}
+
+func scanPostRevision(row rowScanner) (*PostRevision, error) {
+	revision := &PostRevision{}
+	err := row.Scan(
+		&revision.ID,
+		&revision.PostID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Content,
+		pq.Array(&revision.Tags),
+		&revision.EditorID,
+		&revision.CreatedAt,
+		&revision.Editor.Username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	revision.Editor.ID = revision.EditorID
+
+	return revision, nil
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DefaultLanguage is the text search configuration posts are stemmed with
+// when their author doesn't pick one.
+const DefaultLanguage = "english"
+
+// headlineOptions marks the matches in snippets with control characters, the
+// snippet is escaped before they become <mark> tags.
+const headlineOptions = "StartSel=\x02, StopSel=\x03, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// SearchQuery is a full-text search. Query is in the syntax of to_tsquery and
+// is stemmed with the Language text search configuration.
+type SearchQuery struct {
+	Query    string
+	Language string
+	// Tag and Author narrow posts to a tag and to a username
+	Tag    string
+	Author string
+	Limit  int
+	Offset int
+}
+
+type PostSearchResult struct {
+	PostWithMetadata
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type CommentSearchResult struct {
+	Comment
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type UserSearchResult struct {
+	ID       int64   `json:"id"`
+	Username string  `json:"username"`
+	Rank     float64 `json:"rank"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// Posts returns the published posts viewerID can see matching the query, the
+// best matches first. Matches in the title rank higher than in the content.
+func (s *SearchStore) Posts(ctx context.Context, viewerID int64, sq SearchQuery) ([]PostSearchResult, error) {
+	query := `
+	select p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status,
+	p.visibility, p.language, p.content_warning, p.sensitive, p.content_warning_forced, p.pinned_at,
+	p.expires_at, u.username,
+	(select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL) as comments_count,
+	ts_rank(p.search_vector, q) as rank,
+	ts_headline($1::regconfig, p.content, q, $3)
+	from posts p join users u on u.id = p.user_id, to_tsquery($1::regconfig, $2) q
+	where p.search_vector @@ q AND p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$4") + ` AND ` + displayableTo("$4") + `
+	AND ($7::text = '' OR $7 = ANY(p.tags)) AND ($8::text = '' OR u.username = $8)
+	order by rank desc, p.created_at desc, p.id desc
+	LIMIT $5 OFFSET $6`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Language, sq.Query, headlineOptions, viewerID, sq.Limit, sq.Offset, sq.Tag, sq.Author)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []PostSearchResult{}
+	for rows.Next() {
+		var r PostSearchResult
+		err := rows.Scan(
+			&r.ID,
+			&r.UserID,
+			&r.Title,
+			&r.Content,
+			&r.ContentHTML,
+			&r.CreatedAt,
+			&r.Version,
+			pq.Array(&r.Tags),
+			&r.Status,
+			&r.Visibility,
+			&r.Language,
+			&r.ContentWarning,
+			&r.Sensitive,
+			&r.WarningForced,
+			&r.PinnedAt,
+			&r.ExpiresAt,
+			&r.User.Username,
+			&r.CommentCount,
+			&r.Rank,
+			&r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.User.ID = r.UserID
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(results))
+	for i := range results {
+		posts[i] = &results[i].Post
+	}
+
+	if err := attachPostMentions(ctx, s.db, posts...); err != nil {
+		return nil, err
+	}
+
+	renderMissingPosts(posts...)
+
+	if err := attachMedia(ctx, s.db, posts...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPreviews(ctx, s.db, posts...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPolls(ctx, s.db, viewerID, posts...); err != nil {
+		return nil, err
+	}
+
+	if err := attachDisplay(ctx, s.db, viewerID, posts...); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Comments returns the comments matching the query left on posts viewerID
+// can see, the best matches first.
+func (s *SearchStore) Comments(ctx context.Context, viewerID int64, sq SearchQuery) ([]CommentSearchResult, error) {
+	query := `
+	select c.id, c.post_id, c.parent_id, c.depth, c.user_id, c.content, COALESCE(c.content_html, ''), c.created_at, c.edited_at,
+	(select count(*) from comments r where r.parent_id = c.id) as reply_count,
+	u.username, u.id,
+	ts_rank(c.search_vector, q) as rank,
+	ts_headline($1::regconfig, c.content, q, $3)
+	from comments c join users u on u.id = c.user_id join posts p on p.id = c.post_id, to_tsquery($1::regconfig, $2) q
+	where c.search_vector @@ q AND c.deleted_at IS NULL AND p.status = 'published' AND p.deleted_at IS NULL
+	AND ` + visibleTo("$4") + ` AND ` + displayableTo("$4") + `
+	order by rank desc, c.created_at desc, c.id desc
+	LIMIT $5 OFFSET $6`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Language, sq.Query, headlineOptions, viewerID, sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []CommentSearchResult{}
+	for rows.Next() {
+		var r CommentSearchResult
+		err := rows.Scan(
+			&r.ID,
+			&r.PostID,
+			&r.ParentID,
+			&r.Depth,
+			&r.UserID,
+			&r.Content,
+			&r.ContentHTML,
+			&r.CreatedAt,
+			&r.EditedAt,
+			&r.ReplyCount,
+			&r.User.Username,
+			&r.User.ID,
+			&r.Rank,
+			&r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	comments := make([]*Comment, len(results))
+	for i := range results {
+		comments[i] = &results[i].Comment
+	}
+
+	if err := attachCommentMentions(ctx, s.db, comments...); err != nil {
+		return nil, err
+	}
+
+	renderMissingComments(comments...)
+
+	return results, nil
+}
+
+// Users returns the active users whose username matches the query. Usernames
+// are not stemmed, so the language doesn't matter.
+func (s *SearchStore) Users(ctx context.Context, sq SearchQuery) ([]UserSearchResult, error) {
+	query := `
+	select u.id, u.username, ts_rank(u.search_vector, q) as rank
+	from users u, to_tsquery('simple', $1) q
+	where u.search_vector @@ q AND u.is_active
+	order by rank desc, u.username
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Query, sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var r UserSearchResult
+		if err := rows.Scan(&r.ID, &r.Username, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// highlight escapes a snippet from ts_headline and turns its match markers
+// into <mark> tags.
+func highlight(snippet string) string {
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/search"
+)
+
+// searchablePosts are the posts a search index keeps, published ones that
+// are neither in the trash nor expired. Flagged follows displayableTo.
+const searchablePosts = `
+	select p.id, p.user_id, u.username, p.title, p.content, p.tags, p.visibility,
+	array(select m.user_id from post_mentions m where m.post_id = p.id AND m.active),
+	p.created_at, p.expires_at, (p.sensitive OR p.content_warning <> '')
+	from posts p join users u on u.id = p.user_id
+	where p.status = 'published' AND p.deleted_at IS NULL AND (p.expires_at IS NULL OR p.expires_at > NOW())`
+
+// GetSearchDocuments returns the documents of the posts with the given IDs
+// that belong in a search index, the others should be removed from it.
+func (s *PostStore) GetSearchDocuments(ctx context.Context, ids []int64) ([]search.PostDocument, error) {
+	query := searchablePosts + ` AND p.id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanSearchDocuments(ctx, query, pq.Array(ids))
+}
+
+// ListSearchDocuments returns the documents of the searchable posts after
+// afterID, by ID, to rebuild a search index.
+func (s *PostStore) ListSearchDocuments(ctx context.Context, afterID int64, limit int) ([]search.PostDocument, error) {
+	query := searchablePosts + ` AND p.id > $1 order by p.id LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanSearchDocuments(ctx, query, afterID, limit)
+}
+
+func (s *PostStore) scanSearchDocuments(ctx context.Context, query string, args ...any) ([]search.PostDocument, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []search.PostDocument{}
+	for rows.Next() {
+		var d search.PostDocument
+		err := rows.Scan(
+			&d.ID,
+			&d.AuthorID,
+			&d.Author,
+			&d.Title,
+			&d.Content,
+			pq.Array(&d.Tags),
+			&d.Visibility,
+			pq.Array(&d.Mentions),
+			&d.CreatedAt,
+			&d.ExpiresAt,
+			&d.Flagged,
+		)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+
+	return docs, rows.Err()
+}
+
+// GetSearchDocuments returns the documents of the users with the given IDs
+// that belong in a search index, the active ones.
+func (s *UserStore) GetSearchDocuments(ctx context.Context, ids []int64) ([]search.UserDocument, error) {
+	query := `SELECT id, username FROM users WHERE is_active AND id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanSearchDocuments(ctx, query, pq.Array(ids))
+}
+
+// ListSearchDocuments returns the documents of the active users after
+// afterID, by ID, to rebuild a search index.
+func (s *UserStore) ListSearchDocuments(ctx context.Context, afterID int64, limit int) ([]search.UserDocument, error) {
+	query := `SELECT id, username FROM users WHERE is_active AND id > $1 ORDER BY id LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanSearchDocuments(ctx, query, afterID, limit)
+}
+
+func (s *UserStore) scanSearchDocuments(ctx context.Context, query string, args ...any) ([]search.UserDocument, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []search.UserDocument{}
+	for rows.Next() {
+		var d search.UserDocument
+		if err := rows.Scan(&d.ID, &d.Username); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+
+	return docs, rows.Err()
+}
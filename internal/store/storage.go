@@ -5,6 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"ontopsolutions.net/gasperlf/social/internal/analytics"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+	"ontopsolutions.net/gasperlf/social/internal/ranking"
+	"ontopsolutions.net/gasperlf/social/internal/search"
 )
 
 var (
@@ -19,39 +24,137 @@ type Storage struct {
 	Posts interface {
 		Create(context.Context, *Post) error
 		GetByID(context.Context, int64) (*Post, error)
-		Delete(context.Context, int64) error
-		Update(context.Context, *Post) (*Post, error)
-		GetUserFeed(context.Context, int64, PaginationFeedQuery) ([]PostWithMetadata, error)
+		Delete(context.Context, int64, int64) error
+		Update(context.Context, *Post, int64) (*Post, error)
+		GetUserFeed(context.Context, int64, PaginationFeedQuery) ([]PostWithMetadata, bool, error)
+		PublishScheduled(context.Context, int) ([]int64, error)
+		GetTrash(context.Context, int64, time.Time) ([]Post, error)
+		Restore(context.Context, int64, int64, time.Time) error
+		PurgeDeleted(context.Context, time.Time, int) ([]int64, error)
+		CanView(context.Context, int64, *Post) (bool, error)
+		GetUserPosts(context.Context, int64, int64, UserPostsQuery) ([]PostWithMetadata, bool, error)
+		Pin(context.Context, int64, int64) error
+		Unpin(context.Context, int64, int64) error
+		Extend(context.Context, int64, int64, time.Time) error
+		PurgeExpired(context.Context, int) ([]int64, []Attachment, error)
+		GetTimelineEntries(context.Context, []int64, *cursor.Cursor, int) ([]TimelineEntry, error)
+		GetByIDs(context.Context, int64, []int64) ([]PostWithMetadata, error)
+		GetRankingCandidates(context.Context, int64, time.Time, int) ([]ranking.Candidate, error)
+		GetRecentPublic(context.Context, int) ([]PostWithMetadata, error)
+		GetPopular(context.Context, time.Duration, int) ([]PostWithMetadata, error)
+		GetSearchDocuments(context.Context, []int64) ([]search.PostDocument, error)
+		ListSearchDocuments(context.Context, int64, int) ([]search.PostDocument, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
 		GetByID(context.Context, int64) (*User, error)
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
-		Activate(context.Context, string) error
+		Activate(context.Context, string) (*User, error)
 		Delete(context.Context, int64) error
 		GetByEmail(context.Context, string) (*User, error)
+		GetSuggested(context.Context, time.Duration, int) ([]SuggestedUser, error)
+		GetSearchDocuments(context.Context, []int64) ([]search.UserDocument, error)
+		ListSearchDocuments(context.Context, int64, int) ([]search.UserDocument, error)
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		GetByPostID(context.Context, int64, CommentPaginationQuery) ([]Comment, bool, error)
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64, int64) error
 		DeleteByPostID(context.Context, int64) error
 	}
+	PostRevisions interface {
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+		GetByVersion(context.Context, int64, int) (*PostRevision, error)
+	}
+	Mentions interface {
+		ClaimPostMentions(context.Context, int64) ([]User, error)
+		ClaimCommentMentions(context.Context, int64) ([]User, error)
+	}
+	Tags interface {
+		GetPosts(context.Context, string, int64, PaginationFeedQuery) ([]PostWithMetadata, error)
+		GetTrending(context.Context, time.Duration, time.Duration, int) ([]TrendingTag, error)
+	}
+	Polls interface {
+		GetByPostID(context.Context, int64, int64) (*Poll, error)
+		Vote(context.Context, int64, int64, []int64) error
+	}
+	Attachments interface {
+		Create(context.Context, *Attachment) error
+		DeleteOrphaned(context.Context, time.Time, int) ([]Attachment, error)
+		CanView(context.Context, int64, string) (bool, error)
+	}
+	LinkPreviews interface {
+		ClaimPending(context.Context, int) ([]string, error)
+		Save(context.Context, *LinkPreview) error
+		MarkFailed(context.Context, string) error
+	}
+	Preferences interface {
+		Get(context.Context, int64) (*Preferences, error)
+		Update(context.Context, *Preferences) error
+	}
+	PostViews interface {
+		Add(context.Context, analytics.Batch) error
+		GetStats(context.Context, int64, time.Time) (*PostStats, error)
+	}
+	Moderation interface {
+		ForceContentWarning(context.Context, int64, int64, string, string) error
+	}
 	Followers interface {
 		Follow(context.Context, int64, int64) error
 		Unfollow(context.Context, int64, int64) error
+		GetFollowing(context.Context, int64) ([]Followee, error)
+		IsMutual(context.Context, int64, int64) (bool, error)
+		CountFollowers(context.Context, int64) (int, error)
+		GetFollowerIDs(context.Context, int64) ([]int64, error)
+	}
+	Search interface {
+		Posts(context.Context, int64, SearchQuery) ([]PostSearchResult, error)
+		Comments(context.Context, int64, SearchQuery) ([]CommentSearchResult, error)
+		Users(context.Context, SearchQuery) ([]UserSearchResult, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Notifications interface {
+		Add(context.Context, NotificationEvent) (int64, bool, error)
+		List(context.Context, int64, NotificationsQuery) ([]Notification, bool, error)
+		MarkRead(context.Context, int64, int64) error
+		MarkAllRead(context.Context, int64) error
+		CountUnread(context.Context, int64) (int, error)
+		HoldEmail(context.Context, NotificationEvent, time.Time) error
+		ReleaseHeldEmails(context.Context, time.Time, int) ([]NotificationEvent, error)
+	}
+	NotificationSettings interface {
+		Get(context.Context, int64) (*NotificationSettings, error)
+		Update(context.Context, *NotificationSettings) error
+		DueDigests(context.Context, time.Time, int64, int) ([]DigestRecipient, error)
+		ClaimDigest(context.Context, int64, *time.Time, time.Time) (bool, error)
+		ReleaseDigest(context.Context, int64, *time.Time, time.Time) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UserStore{db: db},
-		Comments:  &CommentStore{db: db},
-		Followers: &FollowerStore{db: db},
-		Roles:     &RoleStore{db: db},
+		Posts:         &PostStore{db: db},
+		Users:         &UserStore{db: db},
+		Comments:      &CommentStore{db: db},
+		PostRevisions: &PostRevisionStore{db: db},
+		Mentions:      &MentionStore{db: db},
+		Tags:          &TagStore{db: db},
+		Polls:         &PollStore{db: db},
+		Attachments:   &AttachmentStore{db: db},
+		LinkPreviews:  &LinkPreviewStore{db: db},
+		Preferences:   &PreferenceStore{db: db},
+		Moderation:    &ModerationStore{db: db},
+		PostViews:     &PostViewStore{db: db},
+		Followers:     &FollowerStore{db: db},
+		Search:        &SearchStore{db: db},
+		Roles:         &RoleStore{db: db},
+		Notifications: &NotificationStore{db: db},
+
+		NotificationSettings: &NotificationSettingStore{db: db},
 	}
 }
 
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TrendingTag struct {
+	Tag   string  `json:"tag"`
+	Uses  int     `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// GetPosts returns the published posts tagged with tag that viewerID can see.
+func (s *TagStore) GetPosts(ctx context.Context, tag string, viewerID int64, fq PaginationFeedQuery) ([]PostWithMetadata, error) {
+	query := `
+	select p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status, p.visibility,
+	p.content_warning, p.sensitive, p.content_warning_forced, p.expires_at, u.username,
+	(select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL) as comments_count
+	from posts p join users u on u.id = p.user_id
+	where p.tags @> $1 AND p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$2") + ` AND ` + displayableTo("$2") + `
+	order by p.created_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+	LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array([]string{tag}), viewerID, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Status,
+			&p.Visibility,
+			&p.ContentWarning,
+			&p.Sensitive,
+			&p.WarningForced,
+			&p.ExpiresAt,
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		posts = append(posts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachPostMentions(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	renderMissingPosts(feedPosts(posts)...)
+
+	if err := attachMedia(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPreviews(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPolls(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachDisplay(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetTrending ranks the tags of public posts published within window that
+// haven't expired. Each use scores 1 when it is brand new and loses half its
+// weight every halfLife, so tags picking up right now beat the ones that
+// peaked earlier.
+func (s *TagStore) GetTrending(ctx context.Context, window, halfLife time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+	select tag, count(*) as uses,
+	sum(power(0.5, extract(epoch from (NOW() - coalesce(p.publish_at, p.created_at))) / $2)) as score
+	from posts p, unnest(p.tags) as tag
+	where coalesce(p.publish_at, p.created_at) > NOW() - make_interval(secs => $1)
+	AND p.status = 'published' AND p.deleted_at IS NULL AND p.visibility = 'public'
+	AND (p.expires_at IS NULL OR p.expires_at > NOW())
+	group by tag
+	order by score desc, uses desc, tag
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Uses, &t.Score); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
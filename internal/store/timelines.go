@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/cursor"
+)
+
+// TimelineEntry is a post in a home timeline.
+type TimelineEntry struct {
+	PostID    int64
+	CreatedAt time.Time
+}
+
+// GetTimelineEntries returns the newest published posts of the authors, before
+// the position of c when it is set. It is what timelines are built from.
+func (s *PostStore) GetTimelineEntries(ctx context.Context, authorIDs []int64, c *cursor.Cursor, limit int) ([]TimelineEntry, error) {
+	entries := []TimelineEntry{}
+	if len(authorIDs) == 0 {
+		return entries, nil
+	}
+
+	cond, _ := keyset("p", c, true, "$3", "$4")
+	query := `SELECT p.id, p.created_at FROM posts p
+			WHERE p.user_id = ANY($1) AND p.status = 'published' AND p.deleted_at IS NULL
+			AND (p.expires_at IS NULL OR p.expires_at > NOW()) AND ` + cond + `
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $2`
+
+	args := []any{pq.Array(authorIDs), limit}
+	if c != nil {
+		args = append(args, c.CreatedAt, c.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetByIDs loads the posts with the given IDs that viewerID can see in a
+// feed, in the order of ids. Posts that are gone or hidden are left out.
+func (s *PostStore) GetByIDs(ctx context.Context, viewerID int64, ids []int64) ([]PostWithMetadata, error) {
+	if len(ids) == 0 {
+		return []PostWithMetadata{}, nil
+	}
+
+	query := `
+	select p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created_at, p.version, p.tags, p.status,
+	p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.pinned_at,
+	p.expires_at, u.username,
+	(select count(*) from comments c where c.post_id = p.id AND c.deleted_at IS NULL) as comments_count
+	from posts p join users u on u.id = p.user_id
+	where p.id = ANY($1) AND p.status = 'published' AND p.deleted_at IS NULL AND ` + visibleTo("$2") + ` AND ` + displayableTo("$2")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	found, err := s.scanPosts(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]PostWithMetadata, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	posts := make([]PostWithMetadata, 0, len(found))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+
+	if err := attachPostMentions(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	renderMissingPosts(feedPosts(posts)...)
+
+	if err := attachMedia(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPreviews(ctx, s.db, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPolls(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	if err := attachDisplay(ctx, s.db, viewerID, feedPosts(posts)...); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
@@ -114,11 +114,13 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 	})
 }
 
-func (s *UserStore) Activate(ctx context.Context, token string) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+// Activate activates the user invited with token and returns them.
+func (s *UserStore) Activate(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
 		//1. find the user that this token belongs to
-
-		user, err := s.getUserFromInvitation(ctx, tx, token)
+		var err error
+		user, err = s.getUserFromInvitation(ctx, tx, token)
 		if err != nil {
 			return err
 		}
@@ -134,6 +136,11 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
 func (s *UserStore) Delete(ctx context.Context, userID int64) error {
@@ -143,6 +150,11 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 			return err
 		}
 
+		// the follows of the user go away with it
+		if err := s.unfollowAll(ctx, tx, userID); err != nil {
+			return err
+		}
+
 		if err := s.deleteUser(ctx, tx, userID); err != nil {
 			return err
 		}
@@ -250,6 +262,17 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+func (s *UserStore) unfollowAll(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `UPDATE users SET followers_count = followers_count - 1
+			WHERE id IN (SELECT user_id FROM followers WHERE follower_id = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
 func (s *UserStore) deleteUser(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM users WHERE id=$1`
 
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"ontopsolutions.net/gasperlf/social/internal/analytics"
+)
+
+// MaxStatsDays is how far back the stats of a post go.
+const MaxStatsDays = 90
+
+// PostDayStats is the activity on a post during a UTC day.
+type PostDayStats struct {
+	Day           string `json:"day"`
+	Views         int64  `json:"views"`
+	UniqueViewers int64  `json:"unique_viewers"`
+	Comments      int64  `json:"comments"`
+	PollVotes     int64  `json:"poll_votes"`
+}
+
+// PostStats sums up the activity on a post, with the days it covers.
+type PostStats struct {
+	PostID        int64          `json:"post_id"`
+	Views         int64          `json:"views"`
+	UniqueViewers int64          `json:"unique_viewers"`
+	Comments      int64          `json:"comments"`
+	PollVotes     int64          `json:"poll_votes"`
+	Days          []PostDayStats `json:"days"`
+}
+
+type PostViewStore struct {
+	db *sql.DB
+}
+
+// Add flushes a batch of buffered views. Views of posts purged in the
+// meantime are dropped.
+func (s *PostViewStore) Add(ctx context.Context, batch analytics.Batch) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		postIDs []int64
+		days    []string
+		views   []int64
+	)
+	for _, v := range batch.Views {
+		postIDs = append(postIDs, v.PostID)
+		days = append(days, v.Day)
+		views = append(views, v.Views)
+	}
+
+	var (
+		viewerPostIDs []int64
+		viewerDays    []string
+		viewerIDs     []int64
+	)
+	for _, v := range batch.Viewers {
+		viewerPostIDs = append(viewerPostIDs, v.PostID)
+		viewerDays = append(viewerDays, v.Day)
+		viewerIDs = append(viewerIDs, v.ViewerID)
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO post_views (post_id, day, views)
+				SELECT v.post_id, v.day::date, v.views
+				FROM unnest($1::bigint[], $2::text[], $3::bigint[]) AS v(post_id, day, views)
+				WHERE EXISTS (SELECT 1 FROM posts p WHERE p.id = v.post_id)
+				ORDER BY v.post_id, v.day
+				ON CONFLICT (post_id, day) DO UPDATE SET views = post_views.views + EXCLUDED.views`
+
+		if _, err := tx.ExecContext(ctx, query, pq.Array(postIDs), pq.Array(days), pq.Array(views)); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO post_viewers (post_id, day, viewer_id)
+				SELECT v.post_id, v.day::date, v.viewer_id
+				FROM unnest($1::bigint[], $2::text[], $3::bigint[]) AS v(post_id, day, viewer_id)
+				WHERE EXISTS (SELECT 1 FROM posts p WHERE p.id = v.post_id)
+				AND EXISTS (SELECT 1 FROM users u WHERE u.id = v.viewer_id)
+				ON CONFLICT DO NOTHING`
+
+		_, err := tx.ExecContext(ctx, query, pq.Array(viewerPostIDs), pq.Array(viewerDays), pq.Array(viewerIDs))
+		return err
+	})
+}
+
+// GetStats returns the activity on a post for every UTC day from since to
+// today, oldest first. Days without activity are included with zeros.
+func (s *PostViewStore) GetStats(ctx context.Context, postID int64, since time.Time) (*PostStats, error) {
+	query := `WITH days AS (
+				SELECT generate_series($2::date, (NOW() AT TIME ZONE 'UTC')::date, interval '1 day')::date AS day
+			)
+			SELECT to_char(d.day, 'YYYY-MM-DD'),
+			COALESCE((SELECT v.views FROM post_views v WHERE v.post_id = $1 AND v.day = d.day), 0),
+			(SELECT count(*) FROM post_viewers pv WHERE pv.post_id = $1 AND pv.day = d.day),
+			(SELECT count(*) FROM comments c WHERE c.post_id = $1 AND c.deleted_at IS NULL
+				AND (c.created_at AT TIME ZONE 'UTC')::date = d.day),
+			(SELECT count(DISTINCT pvt.user_id) FROM poll_votes pvt JOIN polls pl ON pl.id = pvt.poll_id
+				WHERE pl.post_id = $1 AND (pvt.created_at AT TIME ZONE 'UTC')::date = d.day)
+			FROM days d
+			ORDER BY d.day`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, since.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &PostStats{PostID: postID, Days: []PostDayStats{}}
+	for rows.Next() {
+		var day PostDayStats
+		if err := rows.Scan(&day.Day, &day.Views, &day.UniqueViewers, &day.Comments, &day.PollVotes); err != nil {
+			return nil, err
+		}
+		stats.Days = append(stats.Days, day)
+		stats.Views += day.Views
+		stats.Comments += day.Comments
+		stats.PollVotes += day.PollVotes
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// a viewer coming back on another day is still one viewer overall
+	query = `SELECT count(DISTINCT viewer_id) FROM post_viewers WHERE post_id = $1 AND day >= $2::date`
+	if err := s.db.QueryRowContext(ctx, query, postID, since.UTC().Format(time.DateOnly)).Scan(&stats.UniqueViewers); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+)
+
+// visibleTo is the single definition of who can see a post. It returns a SQL
+// condition on the posts alias p for the viewer bound to viewerParam, a
+// viewer of 0 is anonymous and only sees public posts. Authors always see
+// their own posts, everybody else only published ones matching the post
+// visibility. Expired posts are gone for everybody until they are purged,
+// deleted posts are filtered by the callers.
+func visibleTo(viewerParam string) string {
+	return strings.ReplaceAll(`((p.expires_at IS NULL OR p.expires_at > NOW()) AND (p.user_id = $viewer OR (p.status = 'published' AND (
+		p.visibility = 'public'
+		OR (p.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM followers vf WHERE vf.user_id = p.user_id AND vf.follower_id = $viewer))
+		OR (p.visibility = 'mentioned' AND EXISTS (
+			SELECT 1 FROM post_mentions vm WHERE vm.post_id = p.id AND vm.user_id = $viewer AND vm.active))
+	))))`, "$viewer", viewerParam)
+}
+
+// CanView reports whether viewerID is allowed to see post. Every place that
+// hands out a single post goes through it, the feed queries embed the same
+// condition.
+func (s *PostStore) CanView(ctx context.Context, viewerID int64, post *Post) (bool, error) {
+	// the shortcuts follow visibleTo, expired posts are gone for their
+	// authors too
+	if post.ExpiresAt != nil && !post.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	if post.UserID == viewerID {
+		return true, nil
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM posts p WHERE p.id = $1 AND ` + visibleTo("$2") + `)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, post.ID, viewerID).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCanView(t *testing.T) {
+	ctx := context.Background()
+	// a nil db fails the test if CanView queries it
+	s := &PostStore{}
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		viewer int64
+		post   *Post
+		want   bool
+	}{
+		{
+			name:   "should let authors see their drafts",
+			viewer: 5,
+			post:   &Post{ID: 1, UserID: 5, Status: PostStatusDraft, Visibility: VisibilityMentioned},
+			want:   true,
+		},
+		{
+			name:   "should let authors see their posts until they expire",
+			viewer: 5,
+			post:   &Post{ID: 1, UserID: 5, Status: PostStatusPublished, Visibility: VisibilityPublic, ExpiresAt: &future},
+			want:   true,
+		},
+		{
+			name:   "should hide expired posts from their authors",
+			viewer: 5,
+			post:   &Post{ID: 1, UserID: 5, Status: PostStatusPublished, Visibility: VisibilityPublic, ExpiresAt: &past},
+			want:   false,
+		},
+		{
+			name:   "should hide expired posts from everybody else",
+			viewer: 6,
+			post:   &Post{ID: 1, UserID: 5, Status: PostStatusPublished, Visibility: VisibilityPublic, ExpiresAt: &past},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CanView(ctx, tt.viewer, tt.post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
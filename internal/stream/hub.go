@@ -0,0 +1,274 @@
+// Package stream delivers live events to connected clients. Events are
+// published on topics, a hub hands them to the subscribers of this instance
+// and keeps the recent ones so a client reconnecting can catch up.
+package stream
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event is something that happened on a topic. IDs grow with time, across
+// topics and instances, so a client resumes from the last ID it got.
+type Event struct {
+	ID    int64           `json:"id"`
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+	At    time.Time       `json:"at"`
+}
+
+// UserTopic carries the events meant for a user only.
+func UserTopic(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// AuthorTopic carries the posts of an author, for their followers.
+func AuthorTopic(userID int64) string {
+	return "author:" + strconv.FormatInt(userID, 10)
+}
+
+// PostTopic carries the comments on a post.
+func PostTopic(postID int64) string {
+	return "post:" + strconv.FormatInt(postID, 10)
+}
+
+// DMTopic carries what goes on between two users, whichever way around they
+// are given.
+func DMTopic(a, b int64) string {
+	a, b = min(a, b), max(a, b)
+	return "dm:" + strconv.FormatInt(a, 10) + ":" + strconv.FormatInt(b, 10)
+}
+
+type Config struct {
+	// ReplaySize is how many events are kept per topic for resuming
+	ReplaySize int
+	// ReplayTTL is how long they are kept
+	ReplayTTL time.Duration
+	// BufferSize is how many events a subscriber can fall behind before it
+	// is dropped, it resumes when it reconnects
+	BufferSize int
+}
+
+// Subscription receives the events of its topics on Events, which is closed
+// when the subscriber falls behind or the hub closes.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	topics []string
+	closed bool
+}
+
+type replay struct {
+	events []Event
+	// dropped is the ID of the last event that fell out of the buffer, or
+	// one past the latest event when an event arrived after it
+	dropped int64
+}
+
+type Hub struct {
+	cfg Config
+
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	replay map[string]*replay
+	// since is the last event ID issued before this hub was getting every
+	// event, resuming from before it may miss some
+	since int64
+	// expired is the last dropped event of the topics forgotten once all
+	// their events expired, it stands in for their dropped IDs
+	expired int64
+	// latest is the highest event ID delivered
+	latest int64
+	swept  time.Time
+	closed bool
+}
+
+func NewHub(cfg Config) *Hub {
+	return &Hub{
+		cfg:    cfg,
+		subs:   make(map[string]map[*Subscription]struct{}),
+		replay: make(map[string]*replay),
+		since:  math.MaxInt64,
+	}
+}
+
+// receiving records that the hub gets every event issued after since.
+func (h *Hub) receiving(since int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.since = since
+}
+
+// Subscribe subscribes to topics. When lastID is set it also returns the kept
+// events after it and whether they are all the client missed, when they are
+// not the client has to reload what it shows.
+func (h *Hub) Subscribe(topics []string, lastID int64) (sub *Subscription, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, h.cfg.BufferSize)
+	sub = &Subscription{Events: events, events: events, topics: topics}
+
+	if h.closed {
+		close(events)
+		sub.closed = true
+		return sub, nil, lastID == 0
+	}
+
+	for _, topic := range topics {
+		if h.subs[topic] == nil {
+			h.subs[topic] = make(map[*Subscription]struct{})
+		}
+		h.subs[topic][sub] = struct{}{}
+	}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+
+	complete = lastID >= h.since
+	now := time.Now()
+	for _, topic := range topics {
+		r := h.replay[topic]
+		if r == nil {
+			if lastID < h.expired {
+				complete = false
+			}
+			continue
+		}
+
+		h.expire(r, now)
+		if lastID < r.dropped {
+			complete = false
+		}
+
+		for _, e := range r.events {
+			if e.ID > lastID {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	slices.SortFunc(missed, func(a, b Event) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return sub, missed, complete
+}
+
+// Unsubscribe stops the subscription, it is safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(sub)
+}
+
+// Deliver keeps e for replay and hands it to the subscribers of its topic.
+// It never blocks, subscribers that fell behind are dropped.
+func (h *Hub) Deliver(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	r := h.replay[e.Topic]
+	if r == nil {
+		r = &replay{dropped: h.expired}
+		h.replay[e.Topic] = r
+	}
+
+	// IDs are issued before the events are published, so an event can
+	// arrive after a later one. Clients that left in between resume past it
+	// and would never get it.
+	if e.ID < h.latest {
+		r.dropped = max(r.dropped, h.latest+1)
+	}
+	h.latest = max(h.latest, e.ID)
+
+	r.events = append(r.events, e)
+	if len(r.events) > h.cfg.ReplaySize {
+		n := len(r.events) - h.cfg.ReplaySize
+		r.dropped = max(r.dropped, r.events[n-1].ID)
+		r.events = slices.Delete(r.events, 0, n)
+	}
+	h.expire(r, e.At)
+	h.sweep(e.At)
+
+	for sub := range h.subs[e.Topic] {
+		select {
+		case sub.events <- e:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// Close ends every subscription, for the server to shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			h.drop(sub)
+		}
+	}
+}
+
+// expire drops the events older than the replay TTL.
+func (h *Hub) expire(r *replay, now time.Time) {
+	n := 0
+	for n < len(r.events) && now.Sub(r.events[n].At) > h.cfg.ReplayTTL {
+		n++
+	}
+
+	if n > 0 {
+		r.dropped = max(r.dropped, r.events[n-1].ID)
+		r.events = slices.Delete(r.events, 0, n)
+	}
+}
+
+// sweep forgets the topics whose events all expired, at most once per replay
+// TTL. Most topics, like the comments of a post, go quiet for good.
+func (h *Hub) sweep(now time.Time) {
+	if now.Sub(h.swept) < h.cfg.ReplayTTL {
+		return
+	}
+	h.swept = now
+
+	for topic, r := range h.replay {
+		h.expire(r, now)
+		if len(r.events) == 0 {
+			h.expired = max(h.expired, r.dropped)
+			delete(h.replay, topic)
+		}
+	}
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+
+	for _, topic := range sub.topics {
+		delete(h.subs[topic], sub)
+		if len(h.subs[topic]) == 0 {
+			delete(h.subs, topic)
+		}
+	}
+
+	sub.closed = true
+	close(sub.events)
+}
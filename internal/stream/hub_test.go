@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{ReplaySize: 2, ReplayTTL: time.Minute, BufferSize: 1}
+
+	t.Run("should deliver to the subscribers of the topic", func(t *testing.T) {
+		hub := NewHub(cfg)
+		p := NewLocal(hub)
+
+		sub, _, _ := hub.Subscribe([]string{UserTopic(1)}, 0)
+		other, _, _ := hub.Subscribe([]string{UserTopic(2)}, 0)
+
+		if err := p.Publish(ctx, UserTopic(1), "comment", map[string]int{"post_id": 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		e := <-sub.Events
+		if e.Type != "comment" || string(e.Data) != `{"post_id":1}` {
+			t.Errorf("got %s %s", e.Type, e.Data)
+		}
+		if len(other.Events) != 0 {
+			t.Error("got an event on another topic")
+		}
+	})
+
+	t.Run("should replay the events after the last one", func(t *testing.T) {
+		hub := NewHub(cfg)
+		p := NewLocal(hub)
+
+		sub, _, _ := hub.Subscribe([]string{AuthorTopic(1)}, 0)
+		_ = p.Publish(ctx, AuthorTopic(1), "post", 1)
+		first := <-sub.Events
+		hub.Unsubscribe(sub)
+		_ = p.Publish(ctx, AuthorTopic(1), "post", 2)
+
+		_, missed, complete := hub.Subscribe([]string{AuthorTopic(1)}, first.ID)
+		if !complete {
+			t.Error("got an incomplete replay")
+		}
+		if len(missed) != 1 || string(missed[0].Data) != "2" {
+			t.Errorf("got %d missed events, want the second one", len(missed))
+		}
+	})
+
+	t.Run("should tell when events fell out of the replay", func(t *testing.T) {
+		hub := NewHub(cfg)
+		p := NewLocal(hub)
+
+		sub, _, _ := hub.Subscribe([]string{AuthorTopic(1)}, 0)
+		_ = p.Publish(ctx, AuthorTopic(1), "post", 1)
+		first := <-sub.Events
+		hub.Unsubscribe(sub)
+		for i := range 3 {
+			_ = p.Publish(ctx, AuthorTopic(1), "post", i+2)
+		}
+
+		_, missed, complete := hub.Subscribe([]string{AuthorTopic(1)}, first.ID)
+		if complete {
+			t.Error("got a complete replay, two events were dropped")
+		}
+		if len(missed) != 2 {
+			t.Errorf("got %d missed events, want 2", len(missed))
+		}
+	})
+
+	t.Run("should forget topics whose events all expired", func(t *testing.T) {
+		hub := NewHub(cfg)
+		hub.receiving(0)
+
+		start := time.Now()
+		hub.Deliver(Event{ID: 5, Topic: PostTopic(1), Type: "comment", At: start})
+		hub.Deliver(Event{ID: 6, Topic: PostTopic(2), Type: "comment", At: start.Add(2 * cfg.ReplayTTL)})
+
+		if _, ok := hub.replay[PostTopic(1)]; ok || len(hub.replay) != 1 {
+			t.Errorf("got %d topics kept, want only the live one", len(hub.replay))
+		}
+
+		if _, _, complete := hub.Subscribe([]string{PostTopic(1)}, 4); complete {
+			t.Error("got a complete replay, the expired event was missed")
+		}
+		if _, _, complete := hub.Subscribe([]string{PostTopic(1)}, 5); !complete {
+			t.Error("got an incomplete replay after the expired event")
+		}
+	})
+
+	t.Run("should not resume from before the hub started", func(t *testing.T) {
+		hub := NewHub(cfg)
+		NewLocal(hub)
+
+		_, _, complete := hub.Subscribe([]string{UserTopic(1)}, 1)
+		if complete {
+			t.Error("got a complete replay")
+		}
+	})
+
+	t.Run("should tell when an event arrived after a later one", func(t *testing.T) {
+		hub := NewHub(cfg)
+		hub.receiving(0)
+
+		now := time.Now()
+		hub.Deliver(Event{ID: 2, Topic: AuthorTopic(1), At: now})
+		hub.Deliver(Event{ID: 1, Topic: AuthorTopic(1), At: now})
+
+		_, missed, complete := hub.Subscribe([]string{AuthorTopic(1)}, 2)
+		if complete {
+			t.Error("got a complete replay")
+		}
+		if len(missed) != 0 {
+			t.Errorf("got %d missed events, want none", len(missed))
+		}
+
+		hub.Deliver(Event{ID: 3, Topic: AuthorTopic(1), At: now})
+		if _, _, complete := hub.Subscribe([]string{AuthorTopic(1)}, 3); !complete {
+			t.Error("got an incomplete replay from after the late event")
+		}
+	})
+
+	t.Run("should drop subscribers that fall behind", func(t *testing.T) {
+		hub := NewHub(cfg)
+		p := NewLocal(hub)
+
+		sub, _, _ := hub.Subscribe([]string{UserTopic(1)}, 0)
+		for i := range 2 {
+			_ = p.Publish(ctx, UserTopic(1), "notification", i)
+		}
+
+		<-sub.Events
+		if _, ok := <-sub.Events; ok {
+			t.Error("got the subscription open")
+		}
+		hub.Unsubscribe(sub)
+	})
+
+	t.Run("should end the subscriptions on close", func(t *testing.T) {
+		hub := NewHub(cfg)
+
+		sub, _, _ := hub.Subscribe([]string{UserTopic(1)}, 0)
+		hub.Close()
+
+		if _, ok := <-sub.Events; ok {
+			t.Error("got the subscription open")
+		}
+	})
+}
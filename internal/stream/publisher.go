@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Publisher publishes events to the hubs of every instance.
+type Publisher interface {
+	Publish(ctx context.Context, topic, typ string, data any) error
+	// Run delivers the events published anywhere to the local hub until ctx
+	// is done.
+	Run(ctx context.Context) error
+}
+
+// Local publishes to a single hub, for deployments with one instance. IDs are
+// microseconds since the epoch so they keep growing across restarts.
+type Local struct {
+	hub *Hub
+
+	mu     sync.Mutex
+	lastID int64
+}
+
+func NewLocal(hub *Hub) *Local {
+	now := time.Now().UnixMicro()
+	hub.receiving(now)
+
+	return &Local{hub: hub, lastID: now}
+}
+
+func (p *Local) Publish(ctx context.Context, topic, typ string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	p.hub.Deliver(Event{ID: p.nextID(), Topic: topic, Type: typ, Data: raw, At: time.Now()})
+	return nil
+}
+
+func (p *Local) nextID() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastID = max(p.lastID+1, time.Now().UnixMicro())
+	return p.lastID
+}
+
+func (p *Local) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisChannel = "stream-events"
+	redisIDKey   = "stream-event-id"
+)
+
+// Redis publishes through Redis pub/sub so every instance gets every event,
+// whichever instance the client is connected to. IDs come from a shared
+// counter, taken before publishing, so events can arrive out of order. The
+// hub tells the clients who may have missed one to reload.
+type Redis struct {
+	rdb *redis.Client
+	hub *Hub
+}
+
+func NewRedis(rdb *redis.Client, hub *Hub) *Redis {
+	return &Redis{rdb: rdb, hub: hub}
+}
+
+func (p *Redis) Publish(ctx context.Context, topic, typ string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := p.rdb.Incr(ctx, redisIDKey).Result()
+	if err != nil {
+		return err
+	}
+
+	e, err := json.Marshal(Event{ID: id, Topic: topic, Type: typ, Data: raw, At: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return p.rdb.Publish(ctx, redisChannel, e).Err()
+}
+
+func (p *Redis) Run(ctx context.Context) error {
+	sub := p.rdb.Subscribe(ctx, redisChannel)
+	defer sub.Close()
+
+	// the events published until the next run are missed, clients resuming
+	// from before then have to reload
+	defer p.hub.receiving(math.MaxInt64)
+
+	// wait for the subscription, events issued after the counter is read
+	// can't be missed anymore
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+
+	since, err := p.rdb.Get(ctx, redisIDKey).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	p.hub.receiving(since)
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				continue
+			}
+			p.hub.Deliver(e)
+		}
+	}
+}
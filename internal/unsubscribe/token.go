@@ -0,0 +1,59 @@
+// Package unsubscribe signs the tokens of the unsubscribe links in emails, so
+// they work without signing in and can't be forged for another user.
+package unsubscribe
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"ontopsolutions.net/gasperlf/social/internal/signing"
+)
+
+var ErrInvalid = errors.New("invalid unsubscribe token")
+
+// ScopeAll unsubscribes from every email, other scopes are notification types
+// or the digest.
+const ScopeAll = "all"
+
+// Token is what an unsubscribe link stops: the emails of Scope to UserID.
+type Token struct {
+	UserID int64
+	Scope  string
+}
+
+const (
+	version    = 1
+	headerSize = 1 + 8
+	maxScope   = 32
+)
+
+// Signer turns tokens into opaque strings and back. Links in old emails keep
+// working, tokens don't expire.
+type Signer struct {
+	signer *signing.Signer
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{signer: signing.NewSigner(secret)}
+}
+
+func (s *Signer) Encode(t Token) string {
+	buf := make([]byte, headerSize, headerSize+len(t.Scope))
+	buf[0] = version
+	binary.BigEndian.PutUint64(buf[1:], uint64(t.UserID))
+	buf = append(buf, t.Scope...)
+
+	return s.signer.Sign(buf)
+}
+
+func (s *Signer) Decode(token string) (Token, error) {
+	payload, err := s.signer.Verify(token)
+	if err != nil || len(payload) <= headerSize || len(payload) > headerSize+maxScope || payload[0] != version {
+		return Token{}, ErrInvalid
+	}
+
+	return Token{
+		UserID: int64(binary.BigEndian.Uint64(payload[1:])),
+		Scope:  string(payload[headerSize:]),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package unsubscribe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"ontopsolutions.net/gasperlf/social/internal/signing"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner("secret")
+	token := Token{UserID: 42, Scope: "mention"}
+
+	t.Run("should decode what it encoded", func(t *testing.T) {
+		got, err := signer.Decode(signer.Encode(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != token {
+			t.Errorf("got %+v, want %+v", got, token)
+		}
+	})
+
+	t.Run("should reject tokens without a valid scope", func(t *testing.T) {
+		for _, scope := range []string{"", strings.Repeat("a", maxScope+1)} {
+			encoded := signer.Encode(Token{UserID: 1, Scope: scope})
+
+			if _, err := signer.Decode(encoded); !errors.Is(err, ErrInvalid) {
+				t.Errorf("%q: got error %v, want %v", scope, err, ErrInvalid)
+			}
+		}
+	})
+
+	t.Run("should reject signed payloads of another version", func(t *testing.T) {
+		payload := append([]byte{version + 1, 0, 0, 0, 0, 0, 0, 0, 42}, "mention"...)
+		encoded := signing.NewSigner("secret").Sign(payload)
+
+		if _, err := signer.Decode(encoded); !errors.Is(err, ErrInvalid) {
+			t.Errorf("got error %v, want %v", err, ErrInvalid)
+		}
+	})
+}